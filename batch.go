@@ -0,0 +1,89 @@
+package cumi
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchResult holds the outcome of a single request executed by Batch
+type BatchResult struct {
+	Response *Response
+	Err      error
+}
+
+// SetBatchConcurrency limits how many requests Batch executes at once.
+// Zero or a negative value means no limit.
+func (c *Client) SetBatchConcurrency(n int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batchLimit = n
+	return c
+}
+
+// Batch executes the requests concurrently and returns their results in the
+// same order. See BatchContext.
+func (c *Client) Batch(requests ...*Request) []BatchResult {
+	return c.BatchContext(context.Background(), requests...)
+}
+
+// BatchContext executes the requests concurrently, at most SetBatchConcurrency
+// at a time, and returns their results in the same order. Cancelling ctx
+// cancels running requests and skips the ones not yet started. Each request
+// keeps its own context values and deadline.
+func (c *Client) BatchContext(ctx context.Context, requests ...*Request) []BatchResult {
+	c.mu.RLock()
+	limit := c.batchLimit
+	c.mu.RUnlock()
+	if limit <= 0 || limit > len(requests) {
+		limit = len(requests)
+	}
+
+	results := make([]BatchResult, len(requests))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, req := range requests {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(requests); j++ {
+				results[j].Err = ctx.Err()
+			}
+			wg.Wait()
+			return results
+		}
+
+		wg.Add(1)
+		go func(i int, req *Request) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Response, results[i].Err = executeInBatch(ctx, req)
+		}(i, req)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// executeInBatch executes a copy of req that is also cancelled when ctx is
+// done. Streamed bodies keep the context alive until they are closed.
+func executeInBatch(ctx context.Context, req *Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	reqCtx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(ctx, cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+
+	resp, err := req.Clone().SetContext(reqCtx).Execute()
+	if resp != nil && resp.stream != nil {
+		resp.stream = &cancelOnClose{ReadCloser: resp.stream, cancel: release}
+		return resp, err
+	}
+	release()
+	return resp, err
+}
@@ -0,0 +1,183 @@
+package cumi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores responses for conditional GET revalidation
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, entry *CacheEntry, ttl time.Duration)
+	Delete(key string)
+}
+
+// CacheEntry is a cached response body with its validators
+type CacheEntry struct {
+	Body         []byte
+	Header       http.Header
+	ETag         string
+	LastModified string
+	StoredAt     time.Time
+	// VaryHeaders holds the request headers named by the response's Vary
+	// header; the entry is only used for requests with the same values
+	VaryHeaders http.Header
+}
+
+// MemoryCache is an in-memory Cache implementation safe for concurrent use
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]memoryCacheItem
+}
+
+type memoryCacheItem struct {
+	entry     *CacheEntry
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates a new in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryCacheItem)}
+}
+
+// Get returns the entry for key if present and not expired
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.RLock()
+	item, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	if !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
+		m.Delete(key)
+		return nil, false
+	}
+	return item.entry, true
+}
+
+// Set stores an entry for key; a zero ttl keeps the entry until deleted
+func (m *MemoryCache) Set(key string, entry *CacheEntry, ttl time.Duration) {
+	item := memoryCacheItem{entry: entry}
+	if ttl > 0 {
+		item.expiresAt = time.Now().Add(ttl)
+	}
+	m.mu.Lock()
+	m.entries[key] = item
+	m.mu.Unlock()
+}
+
+// Delete removes the entry for key
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+}
+
+// cacheKey returns the cache key for a request, or "" if it is not cacheable.
+// Requests with other credentials (Authorization, cookies including those
+// from the jar) or Accept headers get their own entries; they are hashed so
+// credentials don't end up in the cache.
+func (c *Client) cacheKey(req *Request, httpReq *http.Request) string {
+	if c.cache == nil || httpReq.Method != http.MethodGet {
+		return ""
+	}
+	if hasNoStore(httpReq.Header) {
+		return ""
+	}
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "Accept", "Cookie"} {
+		h.Write([]byte(strings.Join(httpReq.Header.Values(name), ",")))
+		h.Write([]byte{0})
+	}
+	if jar := c.GetCookieJar(); jar != nil && !req.noCookieJar {
+		for _, cookie := range jar.Cookies(httpReq.URL) {
+			h.Write([]byte(cookie.String()))
+			h.Write([]byte{0})
+		}
+	}
+	return httpReq.URL.String() + " " + hex.EncodeToString(h.Sum(nil))
+}
+
+// varyMatches reports whether httpReq has the same values for the headers
+// named by Vary as the request that stored entry. "Vary: *" never matches.
+func varyMatches(entry *CacheEntry, httpReq *http.Request) bool {
+	for _, v := range entry.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			if strings.TrimSpace(name) == "*" {
+				return false
+			}
+		}
+	}
+	for name, values := range entry.VaryHeaders {
+		if strings.Join(httpReq.Header.Values(name), ",") != strings.Join(values, ",") {
+			return false
+		}
+	}
+	return true
+}
+
+// applyCacheValidators adds If-None-Match/If-Modified-Since from a cached entry
+func applyCacheValidators(httpReq *http.Request, entry *CacheEntry) {
+	if entry.ETag != "" && httpReq.Header.Get("If-None-Match") == "" {
+		httpReq.Header.Set("If-None-Match", entry.ETag)
+	}
+	if entry.LastModified != "" && httpReq.Header.Get("If-Modified-Since") == "" {
+		httpReq.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+}
+
+// storeCache saves a successful response to httpReq if it carries validators
+// and allows storing
+func (c *Client) storeCache(key string, httpReq *http.Request, resp *Response) {
+	if resp.StatusCode != http.StatusOK || hasNoStore(resp.Header) {
+		return
+	}
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return
+	}
+
+	var vary http.Header
+	for _, v := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			switch name {
+			case "":
+			case "*":
+				// The response varies on more than request headers
+				return
+			default:
+				if vary == nil {
+					vary = make(http.Header)
+				}
+				vary[name] = append([]string(nil), httpReq.Header.Values(name)...)
+			}
+		}
+	}
+
+	c.cache.Set(key, &CacheEntry{
+		Body:         append([]byte(nil), resp.body...),
+		Header:       resp.Header.Clone(),
+		ETag:         etag,
+		LastModified: lastModified,
+		StoredAt:     time.Now(),
+		VaryHeaders:  vary,
+	}, c.cacheTTL)
+}
+
+// hasNoStore reports whether the Cache-Control header forbids storing
+func hasNoStore(header http.Header) bool {
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(directive), "no-store") {
+				return true
+			}
+		}
+	}
+	return false
+}
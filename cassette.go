@@ -0,0 +1,268 @@
+package cumi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoRecordedInteraction is returned in replay mode when no recorded
+// interaction matches a request
+var ErrNoRecordedInteraction = errors.New("no recorded interaction matches request")
+
+// RecordMode selects how EnableRecorder uses its cassette file
+type RecordMode int
+
+const (
+	// RecordModeRecord sends requests and saves every interaction, replacing the file
+	RecordModeRecord RecordMode = iota
+	// RecordModeReplay serves responses from the file without touching the network
+	RecordModeReplay
+	// RecordModeReplayOrRecord replays matching interactions and records new ones
+	RecordModeReplayOrRecord
+)
+
+// CassetteRequest is the recorded part of a request. Bodies that are not
+// valid UTF-8 are stored base64 encoded, with BodyEncoding set to "base64".
+type CassetteRequest struct {
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// CassetteResponse is the recorded part of a response, with the body stored
+// like CassetteRequest.Body
+type CassetteResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body,omitempty"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+// Interaction is a recorded request/response pair
+type Interaction struct {
+	Request  CassetteRequest  `json:"request"`
+	Response CassetteResponse `json:"response"`
+}
+
+// RecordMatcher reports whether a recorded request matches the actual one
+type RecordMatcher func(actual, recorded CassetteRequest) bool
+
+// DefaultRecordMatcher matches requests by method, URL and body
+func DefaultRecordMatcher(actual, recorded CassetteRequest) bool {
+	return actual.Method == recorded.Method && actual.URL == recorded.URL && actual.Body == recorded.Body
+}
+
+// cassette records and replays interactions for a client
+type cassette struct {
+	mu           sync.Mutex
+	path         string
+	mode         RecordMode
+	matcher      RecordMatcher
+	redact       func(http.Header) http.Header
+	redactBody   func([]byte) []byte
+	interactions []Interaction
+	used         []bool
+}
+
+// EnableRecorder records interactions to, or replays them from, the cassette
+// file at path. Requests are matched by method, URL and body unless
+// SetRecorderMatcher is used. Recorded headers and bodies are redacted like
+// debug output (see SetRedactHeaders and SetBodyRedactor), and requests are
+// matched on their redacted bodies.
+func (c *Client) EnableRecorder(path string, mode RecordMode) *Client {
+	cas := &cassette{path: path, mode: mode, matcher: DefaultRecordMatcher, redact: c.redactHeaderValues, redactBody: c.redactBody}
+	if mode != RecordModeRecord {
+		if err := cas.load(); err != nil {
+			return c.setErr("recorder", err)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.storeErr("recorder", nil)
+	c.cassette = cas
+	c.transportWraps = append(c.transportWraps, cas.wrap)
+	c.buildWrappedTransport()
+	return c
+}
+
+// SetRecorderMatcher sets how requests are matched against recorded interactions
+func (c *Client) SetRecorderMatcher(matcher RecordMatcher) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cassette != nil {
+		c.cassette.mu.Lock()
+		c.cassette.matcher = matcher
+		c.cassette.mu.Unlock()
+	}
+	return c
+}
+
+// load reads the recorded interactions from the cassette file
+func (cas *cassette) load() error {
+	data, err := os.ReadFile(cas.path)
+	if os.IsNotExist(err) && cas.mode == RecordModeReplayOrRecord {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &cas.interactions); err != nil {
+		return fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	cas.used = make([]bool, len(cas.interactions))
+	return nil
+}
+
+// save writes the recorded interactions to the cassette file. Callers must hold cas.mu.
+func (cas *cassette) save() error {
+	data, err := json.MarshalIndent(cas.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(cas.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
+
+// wrap returns a RoundTripper that replays or records requests sent to next
+func (cas *cassette) wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, err := readAndRestore(&req.Body)
+		if err != nil {
+			return nil, err
+		}
+		actual := CassetteRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header,
+		}
+		actual.Body, actual.BodyEncoding = encodeCassetteBody(cas.redactBody(body))
+
+		if cas.mode != RecordModeRecord {
+			if recorded, ok := cas.match(actual); ok {
+				return recorded.toHTTPResponse(req)
+			}
+			if cas.mode == RecordModeReplay {
+				return nil, fmt.Errorf("%w: %s %s", ErrNoRecordedInteraction, req.Method, actual.URL)
+			}
+		}
+
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		respBody, err := readAndRestore(&resp.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		actual.Header = cas.redact(req.Header)
+		recorded := CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Header:     cas.redact(resp.Header),
+		}
+		recorded.Body, recorded.BodyEncoding = encodeCassetteBody(cas.redactBody(respBody))
+		interaction := Interaction{Request: actual, Response: recorded}
+
+		cas.mu.Lock()
+		defer cas.mu.Unlock()
+		cas.interactions = append(cas.interactions, interaction)
+		cas.used = append(cas.used, true)
+		if err := cas.save(); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		return resp, nil
+	})
+}
+
+// match returns the first unused recorded response matching actual, or the
+// last matching one when all have been used
+func (cas *cassette) match(actual CassetteRequest) (CassetteResponse, bool) {
+	cas.mu.Lock()
+	defer cas.mu.Unlock()
+
+	last := -1
+	for i, interaction := range cas.interactions {
+		if !cas.matcher(actual, interaction.Request) {
+			continue
+		}
+		if !cas.used[i] {
+			cas.used[i] = true
+			return interaction.Response, true
+		}
+		last = i
+	}
+	if last >= 0 {
+		return cas.interactions[last].Response, true
+	}
+	return CassetteResponse{}, false
+}
+
+// toHTTPResponse builds the replayed response for req
+func (r CassetteResponse) toHTTPResponse(req *http.Request) (*http.Response, error) {
+	body, err := decodeCassetteBody(r.Body, r.BodyEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        r.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// encodeCassetteBody returns body as stored in a cassette and its encoding
+func encodeCassetteBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+// decodeCassetteBody reverses encodeCassetteBody
+func decodeCassetteBody(body, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(body), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode cassette body: %w", err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("unsupported cassette body encoding %q", encoding)
+}
+
+// readAndRestore reads a body and replaces it with an in-memory copy
+func readAndRestore(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
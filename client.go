@@ -6,18 +6,21 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime/multipart"
+	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 // Client represents an HTTP client with chainable methods
@@ -33,20 +36,60 @@ type Client struct {
 	cookies           []*http.Cookie
 	userAgent         string
 	beforeRequest     []RequestMiddleware
+	beforeAttempt     []RequestMiddleware
 	afterResponse     []ResponseMiddleware
 	jsonMarshal       func(v interface{}) ([]byte, error)
 	jsonUnmarshal     func(data []byte, v interface{}) error
+	jsonUseNumber     bool
+	singleFlight      map[string]bool
+	flightGroup       singleflight.Group
+	strictJSON        bool
+	sniffContent      bool
 	xmlMarshal        func(v interface{}) ([]byte, error)
 	xmlUnmarshal      func(data []byte, v interface{}) error
+	msgpackMarshal    func(v interface{}) ([]byte, error)
+	msgpackUnmarshal  func(data []byte, v interface{}) error
+	decoders          map[string]func(data []byte, v interface{}) error
 	debug             bool
+	debugHook         DebugHook
+	debugBodyLimit    int
 	allowGetPayload   bool
 	retryCount        int
 	retryInterval     time.Duration
-	retryCondition    RetryConditionFunc
+	retryCondition    RetryConditionFullFunc
+	retryableCodes    map[int]bool
+	nonRetryableCodes map[int]bool
+	maxRetryElapsed   time.Duration
+	hostConfigs       map[string]HostConfig
+	contextHeaders    map[string]interface{}
 	errorHandler      ErrorHook
 	onError           ErrorHook
+	onRetry           RetryHook
+	metricsHook       MetricsHook
 	commonErrorResult interface{}
 	resultChecker     func(*Response) ResultState
+	cache             Cache
+	cacheTTL          time.Duration
+	shareCookieJar    bool
+	redactHeaders     map[string]bool
+	bodyRedactor      func([]byte) []byte
+	maxBodySize       int64
+	maxDecompressed   int64
+	keepPartialBody   bool
+	acceptEncoding    string
+	hostOverrides     map[string]string
+	resolver          *net.Resolver
+	dialTimeout       time.Duration
+	expectThreshold   int64
+	batchLimit        int
+	hedgeDelay        time.Duration
+	hedgeMax          int
+	tokenFunc         func(ctx context.Context) (string, error)
+	requestIDHeader   string
+	transportWraps    []TransportWrapper
+	wrappedRT         http.RoundTripper
+	cassette          *cassette
+	errs              []settingErr
 	ctx               context.Context
 }
 
@@ -59,9 +102,23 @@ type ResponseMiddleware func(*Client, *Response) error
 // RetryConditionFunc defines when a request should be retried
 type RetryConditionFunc func(*Response, error) bool
 
-// ErrorHook is called when an error occurs
+// RetryConditionFullFunc defines when a request should be retried, given the
+// number of attempts made so far and the time elapsed since the first one
+type RetryConditionFullFunc func(resp *Response, err error, attempt int, elapsed time.Duration) bool
+
+// ErrorHook is called when an error occurs. The Response is nil when the
+// request failed before it was sent, e.g. on URL or body marshaling errors.
 type ErrorHook func(*Client, *Request, *Response, error)
 
+// RetryHook is called before each retry with the failed attempt number (starting at 1)
+type RetryHook func(resp *Response, err error, attempt int)
+
+// ErrBodyTooLarge is returned when a response body exceeds the configured maximum size
+var ErrBodyTooLarge = errors.New("response body too large")
+
+// ErrMissingPathParam is returned when a URL placeholder has no path parameter value
+var ErrMissingPathParam = errors.New("missing path parameters")
+
 // ResultState represents the state of the response
 type ResultState int
 
@@ -71,7 +128,8 @@ const (
 	UnknownState
 )
 
-// C creates a new client (alias for NewClient)
+// C creates a new client (alias for NewClient).
+// Use DefaultClient or the package-level functions to share one client.
 func C() *Client {
 	return NewClient()
 }
@@ -83,7 +141,13 @@ func NewClient() *Client {
 
 // NewClientWithConfig creates a new HTTP client with provided configuration
 func NewClientWithConfig(config *Config) *Client {
-	jar, _ := cookiejar.New(nil)
+	// Use config's cookie jar or create an in-memory one
+	var jar http.CookieJar
+	if config.CookieJar != nil {
+		jar = config.CookieJar
+	} else {
+		jar = newCookieJar(nil)
+	}
 
 	// Use config's transport or create default
 	var transport http.RoundTripper
@@ -92,9 +156,11 @@ func NewClientWithConfig(config *Config) *Client {
 	} else {
 		tlsConfig := config.TLSConfig
 		if tlsConfig == nil {
-			tlsConfig = &tls.Config{}
+			// Resume TLS sessions to skip full handshakes on new connections
+			tlsConfig = &tls.Config{ClientSessionCache: tls.NewLRUClientSessionCache(0)}
 		}
 		transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
 			TLSClientConfig: tlsConfig,
 		}
 	}
@@ -117,10 +183,10 @@ func NewClientWithConfig(config *Config) *Client {
 		queryParams.Set(k, v)
 	}
 
-	// Convert path params map
+	// Convert path params map, escaping values like SetCommonPathParam
 	pathParams := make(map[string]string)
 	for k, v := range config.PathParams {
-		pathParams[k] = v
+		pathParams[k] = escapePathParam(v)
 	}
 
 	// Set default User-Agent if empty
@@ -168,18 +234,25 @@ func NewClientWithConfig(config *Config) *Client {
 		formData:          make(url.Values),
 		userAgent:         userAgent,
 		debug:             config.Debug,
+		debugBodyLimit:    defaultDebugBodyLimit,
 		allowGetPayload:   config.AllowGetPayload,
 		retryCount:        config.RetryCount,
 		retryInterval:     config.RetryInterval,
-		retryCondition:    config.RetryCondition,
+		retryCondition:    fullRetryCondition(config.RetryCondition),
 		errorHandler:      config.ErrorHandler,
 		onError:           config.OnError,
+		onRetry:           config.OnRetry,
 		commonErrorResult: config.CommonErrorResult,
 		resultChecker:     resultChecker,
+		maxBodySize:       config.MaxBodySize,
+		redactHeaders:     defaultRedactHeaders(),
 		jsonMarshal:       json.Marshal,
 		jsonUnmarshal:     json.Unmarshal,
 		xmlMarshal:        xml.Marshal,
 		xmlUnmarshal:      xml.Unmarshal,
+		msgpackMarshal:    MsgPackMarshal,
+		msgpackUnmarshal:  MsgPackUnmarshal,
+		decoders:          make(map[string]func(data []byte, v interface{}) error),
 		beforeRequest:     append([]RequestMiddleware{}, config.BeforeRequest...),
 		afterResponse:     append([]ResponseMiddleware{}, config.AfterResponse...),
 	}
@@ -209,6 +282,12 @@ func (c *Client) Http() *Request {
 	}
 }
 
+// NewTemplate creates a request to use as a reusable template. Configure the
+// shared headers, query parameters and body once, then Clone it for every call.
+func (c *Client) NewTemplate() *Request {
+	return c.Http()
+}
+
 // Get creates a new GET request
 func (c *Client) Get(url ...string) *Request {
 	r := c.Http()
@@ -279,13 +358,60 @@ func (c *Client) Options(url ...string) *Request {
 	return r
 }
 
-// Clone creates a copy of the client
+// GetJSON sends a GET request and unmarshals a successful response into result
+func (c *Client) GetJSON(url string, result interface{}) (*Response, error) {
+	return c.Get(url).SetSuccessResult(result).Execute()
+}
+
+// PostJSON sends body as JSON and unmarshals a successful response into result
+func (c *Client) PostJSON(url string, body, result interface{}) (*Response, error) {
+	return c.jsonRequest(c.Post(url), body, result)
+}
+
+// PutJSON sends body as JSON and unmarshals a successful response into result
+func (c *Client) PutJSON(url string, body, result interface{}) (*Response, error) {
+	return c.jsonRequest(c.Put(url), body, result)
+}
+
+// PatchJSON sends body as JSON and unmarshals a successful response into result
+func (c *Client) PatchJSON(url string, body, result interface{}) (*Response, error) {
+	return c.jsonRequest(c.Patch(url), body, result)
+}
+
+// DeleteJSON sends body, if any, as JSON and unmarshals a successful response into result
+func (c *Client) DeleteJSON(url string, body, result interface{}) (*Response, error) {
+	return c.jsonRequest(c.Delete(url), body, result)
+}
+
+// jsonRequest sets the JSON body and success result, then executes the request
+func (c *Client) jsonRequest(r *Request, body, result interface{}) (*Response, error) {
+	if body != nil {
+		r.SetBodyJSON(body)
+	}
+	if result != nil {
+		r.SetSuccessResult(result)
+	}
+	return r.Execute()
+}
+
+// Clone creates a copy of the client.
+// The copy gets a fresh cookie jar unless EnableShareCookieJar was called.
 func (c *Client) Clone() *Client {
-	jar, _ := cookiejar.New(nil)
+	var jar http.CookieJar
+	if c.shareCookieJar {
+		jar = c.httpClient.Jar
+	} else if parent, ok := c.httpClient.Jar.(*cookieJar); ok {
+		jar = newCookieJar(parent.options)
+	} else {
+		jar = newCookieJar(nil)
+	}
 
-	transport := &http.Transport{}
-	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+	var transport http.RoundTripper = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	switch t := c.httpClient.Transport.(type) {
+	case *http.Transport:
 		transport = t.Clone()
+	case TransportCloner:
+		transport = t.CloneTransport()
 	}
 
 	httpClient := &http.Client{
@@ -317,7 +443,46 @@ func (c *Client) Clone() *Client {
 	cookies := make([]*http.Cookie, len(c.cookies))
 	copy(cookies, c.cookies)
 
-	return &Client{
+	decoders := make(map[string]func(data []byte, v interface{}) error)
+	for k, v := range c.decoders {
+		decoders[k] = v
+	}
+
+	var hostConfigs map[string]HostConfig
+	for k, v := range c.hostConfigs {
+		if hostConfigs == nil {
+			hostConfigs = make(map[string]HostConfig)
+		}
+		hostConfigs[k] = v
+	}
+
+	var contextHeaders map[string]interface{}
+	for k, v := range c.contextHeaders {
+		if contextHeaders == nil {
+			contextHeaders = make(map[string]interface{})
+		}
+		contextHeaders[k] = v
+	}
+
+	var singleFlight map[string]bool
+	for k, v := range c.singleFlight {
+		if singleFlight == nil {
+			singleFlight = make(map[string]bool)
+		}
+		singleFlight[k] = v
+	}
+
+	redactHeaders := make(map[string]bool)
+	for k, v := range c.redactHeaders {
+		redactHeaders[k] = v
+	}
+
+	hostOverrides := make(map[string]string)
+	for k, v := range c.hostOverrides {
+		hostOverrides[k] = v
+	}
+
+	clone := &Client{
 		httpClient:        httpClient,
 		baseURL:           c.baseURL,
 		timeout:           c.timeout,
@@ -328,29 +493,135 @@ func (c *Client) Clone() *Client {
 		cookies:           cookies,
 		userAgent:         c.userAgent,
 		beforeRequest:     append([]RequestMiddleware(nil), c.beforeRequest...),
+		beforeAttempt:     append([]RequestMiddleware(nil), c.beforeAttempt...),
 		afterResponse:     append([]ResponseMiddleware(nil), c.afterResponse...),
 		jsonMarshal:       c.jsonMarshal,
 		jsonUnmarshal:     c.jsonUnmarshal,
+		jsonUseNumber:     c.jsonUseNumber,
+		singleFlight:      singleFlight,
+		strictJSON:        c.strictJSON,
+		sniffContent:      c.sniffContent,
 		xmlMarshal:        c.xmlMarshal,
 		xmlUnmarshal:      c.xmlUnmarshal,
+		msgpackMarshal:    c.msgpackMarshal,
+		msgpackUnmarshal:  c.msgpackUnmarshal,
+		decoders:          decoders,
 		debug:             c.debug,
+		debugHook:         c.debugHook,
+		debugBodyLimit:    c.debugBodyLimit,
 		allowGetPayload:   c.allowGetPayload,
 		retryCount:        c.retryCount,
 		retryInterval:     c.retryInterval,
 		retryCondition:    c.retryCondition,
+		retryableCodes:    c.retryableCodes,
+		nonRetryableCodes: c.nonRetryableCodes,
+		maxRetryElapsed:   c.maxRetryElapsed,
+		hostConfigs:       hostConfigs,
+		contextHeaders:    contextHeaders,
 		errorHandler:      c.errorHandler,
 		onError:           c.onError,
+		onRetry:           c.onRetry,
+		metricsHook:       c.metricsHook,
 		commonErrorResult: c.commonErrorResult,
 		resultChecker:     c.resultChecker,
+		cache:             c.cache,
+		cacheTTL:          c.cacheTTL,
+		shareCookieJar:    c.shareCookieJar,
+		redactHeaders:     redactHeaders,
+		bodyRedactor:      c.bodyRedactor,
+		maxBodySize:       c.maxBodySize,
+		maxDecompressed:   c.maxDecompressed,
+		keepPartialBody:   c.keepPartialBody,
+		acceptEncoding:    c.acceptEncoding,
+		hostOverrides:     hostOverrides,
+		resolver:          c.resolver,
+		dialTimeout:       c.dialTimeout,
+		expectThreshold:   c.expectThreshold,
+		batchLimit:        c.batchLimit,
+		hedgeDelay:        c.hedgeDelay,
+		hedgeMax:          c.hedgeMax,
+		tokenFunc:         c.tokenFunc,
+		requestIDHeader:   c.requestIDHeader,
+		transportWraps:    append([]TransportWrapper(nil), c.transportWraps...),
+		cassette:          c.cassette,
+		errs:              append([]settingErr(nil), c.errs...),
 		ctx:               c.ctx,
 	}
+
+	// Re-bind the custom dialer so the clone uses its own host overrides and dial timeout
+	if len(hostOverrides) > 0 || c.resolver != nil || c.dialTimeout > 0 {
+		clone.installDialer()
+	}
+	// Sessions from the parent's cache would skip the clone's own certificate settings
+	if transport, ok := clone.httpTransport(); ok && transport.TLSClientConfig != nil {
+		resetSessionCache(transport.TLSClientConfig)
+	}
+	clone.buildWrappedTransport()
+
+	return clone
 }
 
-// SetBaseURL sets the base URL for the client
+// CloneWithCookies creates a copy of the client with its own cookie jar that
+// starts with a copy of the parent's cookies (e.g. login sessions), keeping
+// their domain, path, secure flag and expiry. Custom jars set with
+// SetCookieJar can't be copied, so the clone shares them.
+func (c *Client) CloneWithCookies() *Client {
+	clone := c.Clone()
+
+	c.mu.RLock()
+	parentJar := c.httpClient.Jar
+	c.mu.RUnlock()
+
+	if jar, ok := parentJar.(*cookieJar); ok && clone.httpClient.Jar != parentJar {
+		clone.httpClient.Jar = jar.clone()
+	} else {
+		clone.httpClient.Jar = parentJar
+	}
+	return clone
+}
+
+// SetBaseURL sets the base URL for the client. An invalid URL is reported
+// when a request is executed, until a valid base URL is set.
 func (c *Client) SetBaseURL(baseURL string) *Client {
+	if err := c.SetBaseURLE(baseURL); err != nil {
+		c.setErr("baseURL", err)
+	}
+	return c
+}
+
+// SetBaseURLE sets the base URL for the client and returns any validation error
+func (c *Client) SetBaseURLE(baseURL string) error {
+	normalized, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseURL = normalized
+	c.storeErr("baseURL", nil)
+	return nil
+}
+
+// SetHeaderFromContext sets headerName on every request from the value stored
+// under key in the request context, e.g. a tenant ID. The header is skipped
+// when the value is absent, and request headers take precedence.
+func (c *Client) SetHeaderFromContext(headerName string, key interface{}) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.baseURL = strings.TrimRight(baseURL, "/")
+	if c.contextHeaders == nil {
+		c.contextHeaders = make(map[string]interface{})
+	}
+	c.contextHeaders[headerName] = key
+	return c
+}
+
+// SetBearerTokenFunc fetches the bearer token from fn, for tokens that rotate.
+// fn is called once per request and again when a retry follows a 401 response.
+// Request, host and explicit Authorization headers take precedence.
+func (c *Client) SetBearerTokenFunc(fn func(ctx context.Context) (string, error)) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokenFunc = fn
 	return c
 }
 
@@ -415,27 +686,30 @@ func (c *Client) SetCommonQueryParams(params map[string]string) *Client {
 	return c
 }
 
-// SetCommonPathParam sets a path parameter that will be used for URL replacement
+// SetCommonPathParam sets a path parameter that will be used for URL replacement.
+// The value is path-escaped, so "a/b c" becomes a single "a%2Fb%20c" segment
+// and ".." stays a literal "%2E%2E" segment.
 func (c *Client) SetCommonPathParam(key, value string) *Client {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	if c.pathParams == nil {
-		c.pathParams = make(map[string]string)
-	}
-	c.pathParams[key] = value
-	return c
+	return c.SetCommonRawPathParam(key, escapePathParam(value))
 }
 
 // SetCommonPathParams sets multiple path parameters from a map
 func (c *Client) SetCommonPathParams(params map[string]string) *Client {
+	for k, v := range params {
+		c.SetCommonPathParam(k, v)
+	}
+	return c
+}
+
+// SetCommonRawPathParam sets a path parameter without escaping it, for values
+// that intentionally span several path segments
+func (c *Client) SetCommonRawPathParam(key, value string) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if c.pathParams == nil {
 		c.pathParams = make(map[string]string)
 	}
-	for k, v := range params {
-		c.pathParams[k] = v
-	}
+	c.pathParams[key] = value
 	return c
 }
 
@@ -457,6 +731,70 @@ func (c *Client) SetCommonCookies(cookies ...*http.Cookie) *Client {
 	return c
 }
 
+// SetCommonSimpleCookies sets cookies sent with every request from name/value pairs
+func (c *Client) SetCommonSimpleCookies(cookies map[string]string) *Client {
+	return c.SetCommonCookies(simpleCookies(cookies)...)
+}
+
+// SetCookieJar sets the cookie jar used to store cookies between requests
+func (c *Client) SetCookieJar(jar http.CookieJar) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.httpClient.Jar = jar
+	return c
+}
+
+// GetCookieJar returns the cookie jar used by the client
+func (c *Client) GetCookieJar() http.CookieJar {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.httpClient.Jar
+}
+
+// Cookies returns the cookies the jar would send to the given URL. A relative
+// URL is resolved against the base URL.
+func (c *Client) Cookies(rawURL string) []*http.Cookie {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.httpClient.Jar == nil {
+		return nil
+	}
+	u, err := joinURL(c.baseURL, rawURL)
+	if err != nil {
+		return nil
+	}
+	return c.httpClient.Jar.Cookies(u)
+}
+
+// ClearCookies replaces the cookie jar with a new empty one with the same
+// options, dropping every stored cookie. Clones sharing the old jar keep it.
+// Custom jars set with SetCookieJar are kept as is, unless they were created
+// with NewCookieJar.
+func (c *Client) ClearCookies() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if jar, ok := c.httpClient.Jar.(*cookieJar); ok {
+		c.httpClient.Jar = newCookieJar(jar.options)
+	}
+	return c
+}
+
+// EnableShareCookieJar makes clones share this client's cookie jar
+func (c *Client) EnableShareCookieJar() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shareCookieJar = true
+	return c
+}
+
+// DisableShareCookieJar gives each clone its own fresh cookie jar
+func (c *Client) DisableShareCookieJar() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shareCookieJar = false
+	return c
+}
+
 // EnableDebug enables debug mode
 func (c *Client) EnableDebug() *Client {
 	c.mu.Lock()
@@ -473,6 +811,46 @@ func (c *Client) DisableDebug() *Client {
 	return c
 }
 
+// SetRedactHeaders masks the given headers in debug output, in addition to
+// the defaults (Authorization, Cookie, Set-Cookie)
+func (c *Client) SetRedactHeaders(keys ...string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.redactHeaders == nil {
+		c.redactHeaders = defaultRedactHeaders()
+	}
+	for _, key := range keys {
+		c.redactHeaders[http.CanonicalHeaderKey(key)] = true
+	}
+	return c
+}
+
+// SetBodyRedactor sets a function that masks sensitive data in bodies before they are logged in debug mode
+func (c *Client) SetBodyRedactor(fn func([]byte) []byte) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bodyRedactor = fn
+	return c
+}
+
+// SetDebugHook sets a hook that receives structured debug events for requests,
+// responses, retries and errors instead of logging them
+func (c *Client) SetDebugHook(hook DebugHook) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.debugHook = hook
+	return c
+}
+
+// SetDebugBodyLimit sets how many characters of request and response bodies
+// are logged in debug output. Zero logs bodies in full. The default is 300.
+func (c *Client) SetDebugBodyLimit(n int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.debugBodyLimit = n
+	return c
+}
+
 // DevMode enables debug mode (alias for EnableDebug)
 func (c *Client) DevMode() *Client {
 	return c.EnableDebug()
@@ -486,7 +864,7 @@ func (c *Client) EnableAllowGetMethodPayload() *Client {
 	return c
 }
 
-// DisableAllowGetMethodPayload disallows GET requests to have a body
+// DisableAllowGetMethodPayload disallows GET requests to have a body; any body set on a GET request is dropped
 func (c *Client) DisableAllowGetMethodPayload() *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -498,7 +876,7 @@ func (c *Client) DisableAllowGetMethodPayload() *Client {
 func (c *Client) SetTLSClientConfig(config *tls.Config) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+	if transport, ok := c.httpTransport(); ok {
 		transport.TLSClientConfig = config
 	}
 	return c
@@ -508,7 +886,7 @@ func (c *Client) SetTLSClientConfig(config *tls.Config) *Client {
 func (c *Client) EnableInsecureSkipVerify() *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+	if transport, ok := c.httpTransport(); ok {
 		if transport.TLSClientConfig == nil {
 			transport.TLSClientConfig = &tls.Config{}
 		}
@@ -521,7 +899,7 @@ func (c *Client) EnableInsecureSkipVerify() *Client {
 func (c *Client) DisableInsecureSkipVerify() *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+	if transport, ok := c.httpTransport(); ok {
 		if transport.TLSClientConfig == nil {
 			transport.TLSClientConfig = &tls.Config{}
 		}
@@ -530,16 +908,151 @@ func (c *Client) DisableInsecureSkipVerify() *Client {
 	return c
 }
 
+// SetExpectContinueTimeout sets how long to wait for a 100 Continue before
+// sending the body of a request with an Expect: 100-continue header
+func (c *Client) SetExpectContinueTimeout(d time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.ExpectContinueTimeout = d
+	}
+	return c
+}
+
+// SetExpectContinueThreshold sends Expect: 100-continue for bodies larger than
+// n bytes or of unknown size, so the server can reject them before the upload.
+// It requires SetExpectContinueTimeout. Zero disables the header.
+func (c *Client) SetExpectContinueThreshold(n int64) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expectThreshold = n
+	return c
+}
+
 // SetProxy sets the proxy function
 func (c *Client) SetProxy(proxy func(*http.Request) (*url.URL, error)) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+	if transport, ok := c.httpTransport(); ok {
 		transport.Proxy = proxy
 	}
 	return c
 }
 
+// SetSOCKS5Proxy routes HTTP and HTTPS requests through a SOCKS5 proxy.
+// Username and password are optional. The transport's native SOCKS5 support
+// is used, so TLS settings still apply to the tunneled connection.
+func (c *Client) SetSOCKS5Proxy(address, username, password string) *Client {
+	proxyURL := &url.URL{Scheme: "socks5", Host: address}
+	if username != "" {
+		proxyURL.User = url.UserPassword(username, password)
+	}
+	return c.SetProxy(http.ProxyURL(proxyURL))
+}
+
+// UseEnvironmentProxy uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables
+func (c *Client) UseEnvironmentProxy() *Client {
+	return c.SetProxy(http.ProxyFromEnvironment)
+}
+
+// DisableProxy sends requests directly, ignoring any configured or environment proxy
+func (c *Client) DisableProxy() *Client {
+	return c.SetProxy(nil)
+}
+
+// ForceHTTP2 restricts the transport to HTTP/2, using prior knowledge (h2c) for
+// unencrypted http:// URLs
+func (c *Client) ForceHTTP2() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.Protocols = protocols
+		transport.ForceAttemptHTTP2 = true
+	}
+	return c
+}
+
+// ForceHTTP1 restricts the transport to HTTP/1.1
+func (c *Client) ForceHTTP1() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		protocols := new(http.Protocols)
+		protocols.SetHTTP1(true)
+		transport.Protocols = protocols
+		transport.ForceAttemptHTTP2 = false
+	}
+	return c
+}
+
+// SetIdleConnTimeout closes pooled connections that have been idle for longer
+// than d, so connections dropped by load balancers are not reused. Zero means no limit.
+func (c *Client) SetIdleConnTimeout(d time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.IdleConnTimeout = d
+	}
+	return c
+}
+
+// DisableKeepAlives uses a fresh connection for every request
+func (c *Client) DisableKeepAlives() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.DisableKeepAlives = true
+		transport.CloseIdleConnections()
+	}
+	return c
+}
+
+// EnableKeepAlives reuses connections between requests (the default)
+func (c *Client) EnableKeepAlives() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.DisableKeepAlives = false
+	}
+	return c
+}
+
+// SetAcceptEncoding sends an explicit Accept-Encoding header, such as "gzip"
+// or "br", unless the request sets its own. The transport only decompresses
+// gzip it asked for itself, so responses to these requests keep their raw
+// compressed bytes and Content-Encoding header. Calling it with no values
+// restores the default.
+func (c *Client) SetAcceptEncoding(values ...string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.acceptEncoding = strings.Join(values, ", ")
+	return c
+}
+
+// DisableCompression stops the transport from requesting gzip and
+// transparently decompressing responses
+func (c *Client) DisableCompression() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.DisableCompression = true
+	}
+	return c
+}
+
+// EnableCompression lets the transport request gzip and decompress responses (the default)
+func (c *Client) EnableCompression() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.DisableCompression = false
+	}
+	return c
+}
+
 // SetRetryCount sets the number of retry attempts
 func (c *Client) SetRetryCount(count int) *Client {
 	c.mu.Lock()
@@ -558,12 +1071,74 @@ func (c *Client) SetRetryInterval(interval time.Duration) *Client {
 
 // SetRetryCondition sets the condition for when to retry
 func (c *Client) SetRetryCondition(condition RetryConditionFunc) *Client {
+	return c.SetRetryConditionFull(fullRetryCondition(condition))
+}
+
+// SetRetryConditionFull sets a retry condition that can also inspect the
+// attempt number and the elapsed time, replacing SetRetryCondition
+func (c *Client) SetRetryConditionFull(condition RetryConditionFullFunc) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.retryCondition = condition
 	return c
 }
 
+// SetRetryableStatusCodes adds status codes the default retry condition retries,
+// in addition to 429 and 5xx. Ignored when SetRetryCondition is used.
+func (c *Client) SetRetryableStatusCodes(codes ...int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retryableCodes = statusCodeSet(codes)
+	return c
+}
+
+// SetNonRetryableStatusCodes sets status codes the default retry condition never
+// retries, such as a 503 that should fail fast. Ignored when SetRetryCondition is used.
+func (c *Client) SetNonRetryableStatusCodes(codes ...int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nonRetryableCodes = statusCodeSet(codes)
+	return c
+}
+
+// SetMaxRetryElapsedTime caps the total time spent retrying. No further retry
+// is attempted once waiting for it would exceed d since the first attempt,
+// and the last response or error is returned. Zero means no limit.
+func (c *Client) SetMaxRetryElapsedTime(d time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxRetryElapsed = d
+	return c
+}
+
+// SetMaxResponseBodySize limits how many bytes of a response body are read.
+// Larger bodies fail with ErrBodyTooLarge. Zero means unlimited.
+func (c *Client) SetMaxResponseBodySize(n int64) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBodySize = n
+	return c
+}
+
+// SetMaxDecompressedSize limits how many bytes are read from a transparently
+// decompressed response body, guarding against decompression bombs.
+// Zero falls back to the SetMaxResponseBodySize limit.
+func (c *Client) SetMaxDecompressedSize(n int64) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxDecompressed = n
+	return c
+}
+
+// SetKeepPartialBody keeps the bytes read before a response body read fails,
+// so Response.Body returns the partial content while the error is still returned
+func (c *Client) SetKeepPartialBody(enable bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keepPartialBody = enable
+	return c
+}
+
 // SetCommonErrorResult sets the common error result type
 func (c *Client) SetCommonErrorResult(err interface{}) *Client {
 	c.mu.Lock()
@@ -580,6 +1155,18 @@ func (c *Client) SetResultStateCheckFunc(fn func(*Response) ResultState) *Client
 	return c
 }
 
+// SetCache enables conditional GET caching using the given store.
+// Entries are kept for ttl (zero keeps them until evicted by the store).
+// Responses are cached per URL, Authorization and Accept header, and only
+// reused for requests matching the headers named by their Vary header.
+func (c *Client) SetCache(store Cache, ttl time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = store
+	c.cacheTTL = ttl
+	return c
+}
+
 // OnError sets the error handler
 func (c *Client) OnError(handler ErrorHook) *Client {
 	c.mu.Lock()
@@ -588,7 +1175,24 @@ func (c *Client) OnError(handler ErrorHook) *Client {
 	return c
 }
 
-// OnBeforeRequest adds a middleware that runs before sending the request
+// OnRetry sets a hook called right before each retry sleep
+func (c *Client) OnRetry(hook RetryHook) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onRetry = hook
+	return c
+}
+
+// SetMetricsHook sets a hook that receives metrics once per logical request
+func (c *Client) SetMetricsHook(hook MetricsHook) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.metricsHook = hook
+	return c
+}
+
+// OnBeforeRequest adds a middleware that runs once before sending the request.
+// Before request middlewares run in registration order.
 func (c *Client) OnBeforeRequest(middleware RequestMiddleware) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -596,7 +1200,17 @@ func (c *Client) OnBeforeRequest(middleware RequestMiddleware) *Client {
 	return c
 }
 
-// OnAfterResponse adds a middleware that runs after receiving the response
+// OnBeforeRequestPerAttempt adds a middleware that runs before every attempt,
+// including retries, for work that must be redone each time such as signing
+func (c *Client) OnBeforeRequestPerAttempt(middleware RequestMiddleware) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.beforeAttempt = append(c.beforeAttempt, middleware)
+	return c
+}
+
+// OnAfterResponse adds a middleware that runs after receiving the response.
+// After response middlewares run in registration order.
 func (c *Client) OnAfterResponse(middleware ResponseMiddleware) *Client {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -604,6 +1218,40 @@ func (c *Client) OnAfterResponse(middleware ResponseMiddleware) *Client {
 	return c
 }
 
+// PrependBeforeRequest adds a before request middleware at the front of the chain
+func (c *Client) PrependBeforeRequest(middleware RequestMiddleware) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.beforeRequest = append([]RequestMiddleware{middleware}, c.beforeRequest...)
+	return c
+}
+
+// PrependAfterResponse adds an after response middleware at the front of the chain
+func (c *Client) PrependAfterResponse(middleware ResponseMiddleware) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.afterResponse = append([]ResponseMiddleware{middleware}, c.afterResponse...)
+	return c
+}
+
+// SetAfterResponse replaces the after response middleware chain
+func (c *Client) SetAfterResponse(middlewares ...ResponseMiddleware) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.afterResponse = append([]ResponseMiddleware{}, middlewares...)
+	return c
+}
+
+// ResetMiddleware removes all before request and after response middlewares
+func (c *Client) ResetMiddleware() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.beforeRequest = []RequestMiddleware{}
+	c.beforeAttempt = []RequestMiddleware{}
+	c.afterResponse = []ResponseMiddleware{}
+	return c
+}
+
 // SetJSONMarshal sets the JSON marshal function
 func (c *Client) SetJSONMarshal(fn func(v interface{}) ([]byte, error)) *Client {
 	c.mu.Lock()
@@ -620,6 +1268,26 @@ func (c *Client) SetJSONUnmarshal(fn func(data []byte, v interface{}) error) *Cl
 	return c
 }
 
+// SetUseJSONNumber decodes JSON numbers in results as json.Number instead of
+// float64, so large integer IDs keep their precision. When enabled it takes
+// precedence over SetJSONUnmarshal.
+func (c *Client) SetUseJSONNumber(enable bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jsonUseNumber = enable
+	return c
+}
+
+// SetJSONDisallowUnknownFields makes JSON result binding and Response.JSON fail
+// when the body contains fields missing from the target struct. It can be
+// overridden per request.
+func (c *Client) SetJSONDisallowUnknownFields(enable bool) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.strictJSON = enable
+	return c
+}
+
 // SetXMLMarshal sets the XML marshal function
 func (c *Client) SetXMLMarshal(fn func(v interface{}) ([]byte, error)) *Client {
 	c.mu.Lock()
@@ -636,6 +1304,51 @@ func (c *Client) SetXMLUnmarshal(fn func(data []byte, v interface{}) error) *Cli
 	return c
 }
 
+// SetMsgPackMarshal sets the MessagePack marshal function
+func (c *Client) SetMsgPackMarshal(fn func(v interface{}) ([]byte, error)) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgpackMarshal = fn
+	return c
+}
+
+// SetMsgPackUnmarshal sets the MessagePack unmarshal function
+func (c *Client) SetMsgPackUnmarshal(fn func(data []byte, v interface{}) error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.msgpackUnmarshal = fn
+	return c
+}
+
+// RegisterDecoder registers a decoder used to unmarshal responses with the given
+// Content-Type (parameters such as charset are ignored when matching)
+func (c *Client) RegisterDecoder(contentType string, fn func(data []byte, v interface{}) error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.decoders == nil {
+		c.decoders = make(map[string]func(data []byte, v interface{}) error)
+	}
+	c.decoders[normalizeMediaType(contentType)] = fn
+	return c
+}
+
+// SetTransport replaces the transport used to send requests.
+// Transport specific helpers (TLS, proxy, dialer) only apply to *http.Transport.
+func (c *Client) SetTransport(transport http.RoundTripper) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.httpClient.Transport = transport
+	c.buildWrappedTransport()
+	return c
+}
+
+// GetTransport returns the transport used to send requests
+func (c *Client) GetTransport() http.RoundTripper {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.httpClient.Transport
+}
+
 // GetClient returns the underlying http.Client
 func (c *Client) GetClient() *http.Client {
 	return c.httpClient
@@ -643,55 +1356,132 @@ func (c *Client) GetClient() *http.Client {
 
 // GetTLSClientConfig returns the TLS configuration
 func (c *Client) GetTLSClientConfig() *tls.Config {
-	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+	if transport, ok := c.httpTransport(); ok {
 		return transport.TLSClientConfig
 	}
 	return nil
 }
 
+// jsonMarshalFor returns the request's JSON marshal function, or the client's
+func (c *Client) jsonMarshalFor(req *Request) func(v interface{}) ([]byte, error) {
+	if req.jsonMarshal != nil {
+		return req.jsonMarshal
+	}
+	return c.jsonMarshal
+}
+
+// xmlMarshalFor returns the request's XML marshal function, or the client's
+func (c *Client) xmlMarshalFor(req *Request) func(v interface{}) ([]byte, error) {
+	if req.xmlMarshal != nil {
+		return req.xmlMarshal
+	}
+	return c.xmlMarshal
+}
+
+// reportEarlyError reports an error that ended a request before a response
+// was received to the debug output and the error handler, and returns it
+func (c *Client) reportEarlyError(req *Request, err error) error {
+	if c.debugEnabled() {
+		c.debugError(req, err, 0)
+	}
+	if c.onError != nil {
+		c.onError(c, req, nil, err)
+	}
+	return err
+}
+
+// httpClientFor returns the HTTP client used to send req, swapping in the
+// request-level transport or the wrapped client transport when set, and
+// dropping the cookie jar for requests that opted out of it
+func (c *Client) httpClientFor(req *Request) *http.Client {
+	c.mu.RLock()
+	transport := c.wrappedRT
+	c.mu.RUnlock()
+	if req.transport != nil {
+		transport = req.transport
+	}
+	if transport == nil && !req.noCookieJar {
+		return c.httpClient
+	}
+	httpClient := *c.httpClient
+	if transport != nil {
+		httpClient.Transport = transport
+	}
+	if req.noCookieJar {
+		httpClient.Jar = nil
+	}
+	return &httpClient
+}
+
 // prepareRequest prepares the HTTP request
 func (c *Client) prepareRequest(req *Request) (*http.Request, error) {
+	if err := c.configErr(); err != nil {
+		return nil, err
+	}
+	if req.err != nil {
+		return nil, req.err
+	}
+
 	// Build URL
-	u, err := c.buildURL(req.url, req.pathParams, req.queryParams)
+	u, err := c.buildURL(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build URL: %w", err)
 	}
 
-	// Prepare body
+	// Prepare body. GET requests only carry one when EnableAllowGetMethodPayload is set.
 	var body io.Reader
 	var contentType string
+	allowBody := req.method != http.MethodGet || c.allowGetPayload
 
-	if req.body != nil {
-		if req.bodyType == "json" {
-			jsonData, err := c.jsonMarshal(req.body)
+	if allowBody && req.body != nil {
+		if jsonType, ok := jsonBodyContentTypes[req.bodyType]; ok {
+			jsonData, err := c.jsonMarshalFor(req)(req.body)
 			if err != nil {
 				return nil, fmt.Errorf("failed to marshal JSON: %w", err)
 			}
 			body = bytes.NewReader(jsonData)
-			contentType = "application/json"
+			contentType = jsonType
 		} else if req.bodyType == "xml" {
-			xmlData, err := c.xmlMarshal(req.body)
+			xmlData, err := c.xmlMarshalFor(req)(req.body)
 			if err != nil {
 				return nil, fmt.Errorf("failed to marshal XML: %w", err)
 			}
 			body = bytes.NewReader(xmlData)
 			contentType = "application/xml"
+		} else if req.bodyType == "msgpack" {
+			msgpackData, err := c.msgpackMarshal(req.body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal MessagePack: %w", err)
+			}
+			body = bytes.NewReader(msgpackData)
+			contentType = "application/msgpack"
+		} else if req.bodyType == "proto" {
+			protoData, err := protoMarshal(req.body)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal protobuf: %w", err)
+			}
+			body = bytes.NewReader(protoData)
+			contentType = "application/x-protobuf"
+		} else if build, ok := req.body.(func(w *multipart.Writer) error); ok && req.bodyType == "multipart" {
+			body, contentType = newMultipartReader(build)
 		} else if data, ok := req.body.([]byte); ok {
 			body = bytes.NewReader(data)
 		} else if s, ok := req.body.(string); ok {
 			body = strings.NewReader(s)
+		} else if sr, ok := req.body.(*sizedReader); ok {
+			body = sr.Reader
 		} else if r, ok := req.body.(io.Reader); ok {
 			body = r
 		} else {
 			// Auto-detect: if it's a struct, marshal as JSON by default
-			jsonData, err := c.jsonMarshal(req.body)
+			jsonData, err := c.jsonMarshalFor(req)(req.body)
 			if err != nil {
 				return nil, fmt.Errorf("failed to marshal body as JSON: %w", err)
 			}
 			body = bytes.NewReader(jsonData)
 			contentType = "application/json"
 		}
-	} else if len(req.formData) > 0 || len(c.formData) > 0 {
+	} else if allowBody && (len(req.formData) > 0 || len(c.formData) > 0) {
 		// Merge form data
 		formData := make(url.Values)
 		for k, values := range c.formData {
@@ -713,18 +1503,48 @@ func (c *Client) prepareRequest(req *Request) (*http.Request, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
+	if sr, ok := req.body.(*sizedReader); ok && body != nil {
+		httpReq.ContentLength = sr.size
+	}
 
-	// Set headers
+	// Set headers. The client's Content-Type is set below, only with a body.
 	for k, values := range c.headers {
+		if http.CanonicalHeaderKey(k) == "Content-Type" {
+			continue
+		}
 		for _, v := range values {
 			httpReq.Header.Add(k, v)
 		}
 	}
+	// Host and context headers replace client headers, unless the request sets them
+	hostCfg := c.hostConfig(u)
+	if hostCfg != nil {
+		for k, v := range hostCfg.Headers {
+			if req.headers.Get(k) == "" {
+				httpReq.Header.Set(k, v)
+			}
+		}
+	}
+	for header, key := range c.contextHeaders {
+		if value := req.Context().Value(key); value != nil && req.headers.Get(header) == "" {
+			httpReq.Header.Set(header, fmt.Sprint(value))
+		}
+	}
 	for k, values := range req.headers {
 		for _, v := range values {
 			httpReq.Header.Add(k, v)
 		}
 	}
+	// net/http ignores a Host header and sends httpReq.Host instead
+	if host := httpReq.Header.Get("Host"); host != "" {
+		httpReq.Host = host
+	}
+	httpReq.Header.Del("Host")
+
+	// Set the client Accept-Encoding unless the request sets its own
+	if c.acceptEncoding != "" && httpReq.Header.Get("Accept-Encoding") == "" {
+		httpReq.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
 
 	// Set User-Agent with priority: Request > Client Config > Default Go
 	if httpReq.Header.Get("User-Agent") == "" {
@@ -742,20 +1562,70 @@ func (c *Client) prepareRequest(req *Request) (*http.Request, error) {
 		httpReq.Header.Set("User-Agent", userAgent)
 	}
 
-	// Set content type if not already set
-	if httpReq.Header.Get("Content-Type") == "" {
-		// Use content type determined by body type (JSON, XML, form data)
-		httpReq.Header.Set("Content-Type", contentType)
+	// Set content type if not already set and a body is present.
+	// Priority: Request (or host) header > SetContentType > client header > body type (JSON, XML, form data)
+	if body != nil && httpReq.Header.Get("Content-Type") == "" {
+		explicit := req.contentType
+		if explicit == "" {
+			explicit = c.headers.Get("Content-Type")
+		}
+		switch {
+		case explicit != "" && req.bodyType == "multipart":
+			// Keep the generated boundary
+			httpReq.Header.Set("Content-Type", multipartContentType(explicit, contentType))
+		case explicit != "":
+			httpReq.Header.Set("Content-Type", explicit)
+		case contentType != "":
+			httpReq.Header.Set("Content-Type", contentType)
+		}
+	}
+
+	// Set the request ID, keeping one set explicitly on the request
+	if c.requestIDHeader != "" {
+		if id := httpReq.Header.Get(c.requestIDHeader); id != "" {
+			req.requestID = id
+		} else {
+			if req.requestID == "" {
+				req.requestID = RequestIDFromContext(httpReq.Context())
+			}
+			if req.requestID == "" {
+				req.requestID = newUUID()
+			}
+			httpReq.Header.Set(c.requestIDHeader, req.requestID)
+		}
 	}
 
-	// Set basic auth
-	if req.basicAuth.username != "" {
-		httpReq.SetBasicAuth(req.basicAuth.username, req.basicAuth.password)
+	// Set the bearer token from the client token func unless other auth is configured
+	if !req.noAuth && c.tokenFunc != nil && httpReq.Header.Get("Authorization") == "" && req.bearerToken == "" && req.basicAuth.username == "" &&
+		(hostCfg == nil || hostCfg.Username == "" && hostCfg.BearerToken == "") {
+		if req.fetchedToken == "" {
+			token, err := c.tokenFunc(httpReq.Context())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get bearer token: %w", err)
+			}
+			req.fetchedToken = token
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+req.fetchedToken)
 	}
 
-	// Set bearer token
-	if req.bearerToken != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+req.bearerToken)
+	if !req.noAuth {
+		// Set host auth; request auth below replaces it
+		if hostCfg != nil && hostCfg.Username != "" {
+			httpReq.SetBasicAuth(hostCfg.Username, hostCfg.Password)
+		}
+		if hostCfg != nil && hostCfg.BearerToken != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+hostCfg.BearerToken)
+		}
+
+		// Set basic auth
+		if req.basicAuth.username != "" {
+			httpReq.SetBasicAuth(req.basicAuth.username, req.basicAuth.password)
+		}
+
+		// Set bearer token
+		if req.bearerToken != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+req.bearerToken)
+		}
 	}
 
 	// Add cookies
@@ -766,6 +1636,30 @@ func (c *Client) prepareRequest(req *Request) (*http.Request, error) {
 		httpReq.AddCookie(cookie)
 	}
 
+	// Sign last so the signature covers the final headers and body
+	if req.awsSigV4 != nil && !req.noAuth {
+		if err := signAWSSigV4(httpReq, req.awsSigV4, sigV4Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	// Let the server reject large uploads before the body is sent
+	if c.expectThreshold > 0 && httpReq.Body != nil && httpReq.Body != http.NoBody &&
+		(httpReq.ContentLength <= 0 || httpReq.ContentLength > c.expectThreshold) {
+		httpReq.Header.Set("Expect", "100-continue")
+	}
+
+	// Report upload progress as the transport reads the body
+	if req.uploadCallback != nil && httpReq.Body != nil && httpReq.Body != http.NoBody {
+		total := httpReq.ContentLength
+		if total <= 0 {
+			total = -1
+		}
+		wrapRequestBody(httpReq, func(body io.ReadCloser) io.ReadCloser {
+			return &progressReader{ReadCloser: body, total: total, callback: req.uploadCallback}
+		})
+	}
+
 	return httpReq, nil
 }
 
@@ -774,6 +1668,32 @@ func (c *Client) execute(req *Request) (*Response, error) {
 	var lastErr error
 	var resp *Response
 
+	// Apply the per-request (or per-host) timeout or deadline for this execution only
+	timeout := req.timeout
+	if timeout == 0 && req.deadline.IsZero() {
+		if hostCfg := c.hostConfigFor(req); hostCfg != nil {
+			timeout = hostCfg.Timeout
+		}
+	}
+	if timeout > 0 || !req.deadline.IsZero() {
+		originalCtx := req.ctx
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			req.ctx, cancel = context.WithTimeout(req.Context(), timeout)
+		} else {
+			req.ctx, cancel = context.WithDeadline(req.Context(), req.deadline)
+		}
+		defer func() {
+			req.ctx = originalCtx
+			if resp != nil && resp.stream != nil {
+				// Keep the timeout active until the streamed body is closed
+				resp.stream = &cancelOnClose{ReadCloser: resp.stream, cancel: cancel}
+				return
+			}
+			cancel()
+		}()
+	}
+
 	if req.tracer != nil && req.spanName != "" {
 		// Use the existing context (from SetContext or client context) as parent
 		parentCtx := req.Context()
@@ -795,37 +1715,138 @@ func (c *Client) execute(req *Request) (*Response, error) {
 		}()
 	}
 
-	maxAttempts := c.retryCount + 1
+	// Report metrics once the logical request completes
+	var timer *connTimer
+	var attempts int
+	var lastHTTPReq *http.Request
+	var history []AttemptInfo
+	var bytesSent, bytesReceived int64
+	var bodyBytesSent atomic.Int64
+	if c.metricsHook != nil {
+		start := time.Now()
+		defer func() {
+			m := RequestMetrics{
+				Method:       req.method,
+				PathTemplate: pathTemplate(req.url),
+				Attempts:     attempts,
+				Duration:     time.Since(start),
+				Err:          lastErr,
+			}
+			if lastHTTPReq != nil {
+				m.Host = lastHTTPReq.URL.Host
+				if lastHTTPReq.ContentLength > 0 {
+					m.BytesOut = lastHTTPReq.ContentLength
+				}
+			}
+			if resp != nil {
+				m.StatusCode = resp.StatusCode
+				m.BytesIn = resp.size
+				m.Err = resp.Err
+			}
+			if timer != nil {
+				timer.fill(&m)
+			}
+			c.metricsHook(m)
+		}()
+	}
+
+	retryCount, retryInterval, retryCondition := c.retrySettings(req)
+	maxAttempts := retryCount + 1
+	retryStart := time.Now()
+
+	// Run before request middlewares once per logical request so their changes
+	// are applied to the HTTP request
+	for _, middleware := range c.beforeRequest {
+		if err := middleware(c, req); err != nil {
+			lastErr = fmt.Errorf("before request middleware error: %w", err)
+			return nil, c.reportEarlyError(req, lastErr)
+		}
+	}
+
+	// Fetch the token func bearer token once per execution, and again after a
+	// 401. The request ID is likewise kept for all attempts.
+	req.fetchedToken, req.requestID = "", ""
+	defer func() { req.fetchedToken, req.requestID = "", "" }()
+
 	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized {
+			req.fetchedToken = ""
+		}
+
+		// Run per-attempt middlewares (e.g. request signing) before every attempt
+		for _, middleware := range c.beforeAttempt {
+			if err := middleware(c, req); err != nil {
+				lastErr = fmt.Errorf("before request middleware error: %w", err)
+				return nil, c.reportEarlyError(req, lastErr)
+			}
+		}
+
 		// Prepare the HTTP request
 		httpReq, err := c.prepareRequest(req)
 		if err != nil {
-			return nil, err
+			lastErr = err
+			return nil, c.reportEarlyError(req, lastErr)
 		}
 
-		// Debug: Print request details
-		if c.debug {
-			c.debugRequest(httpReq, attempt+1, maxAttempts)
+		// Attach cache validators for conditional GETs
+		var cacheKey string
+		if !req.stream && req.writer == nil && req.downloadPath == "" {
+			cacheKey = c.cacheKey(req, httpReq)
 		}
-
-		// Run before request middlewares
-		for _, middleware := range c.beforeRequest {
-			if err := middleware(c, req); err != nil {
-				return nil, fmt.Errorf("before request middleware error: %w", err)
+		var cached *CacheEntry
+		if cacheKey != "" {
+			if entry, ok := c.cache.Get(cacheKey); ok && varyMatches(entry, httpReq) {
+				cached = entry
+				applyCacheValidators(httpReq, entry)
 			}
 		}
 
+		// Request the remaining bytes when resuming a download
+		resumeOffset, err := applyResumeRange(httpReq, req)
+		if err != nil {
+			lastErr = err
+			return nil, c.reportEarlyError(req, lastErr)
+		}
+
+		// Record connection timings for metrics and Response.TraceInfo
+		httpReq, timer = withConnTimer(httpReq)
+		attempts = attempt + 1
+		lastHTTPReq = httpReq
+
+		// Count bytes on the wire for Response.BytesSent
+		bytesSent += requestHeaderSize(httpReq)
+		if httpReq.Body != nil && httpReq.Body != http.NoBody {
+			wrapRequestBody(httpReq, func(body io.ReadCloser) io.ReadCloser {
+				return &countingReader{ReadCloser: body, n: &bodyBytesSent}
+			})
+		}
+
+		// Debug: Print request details
+		if c.debugEnabled() {
+			c.debugRequest(httpReq, attempt+1, maxAttempts)
+		}
+
 		// Execute the request
 		startTime := time.Now()
-		httpResp, err := c.httpClient.Do(httpReq)
+		httpResp, err := c.send(req, httpReq)
 		duration := time.Since(startTime)
+		if err != nil {
+			err = c.classifyError(httpReq.Context(), startTime, err)
+		}
+
+		// Keep a summary of the previous attempt before replacing it
+		if resp != nil {
+			history = append(history, resp.attemptInfo())
+		}
 
 		// Create response
 		resp = &Response{
 			Request:    req,
 			Response:   httpResp,
+			requestID:  req.requestID,
 			receivedAt: time.Now(),
 			duration:   duration,
+			timer:      timer,
 		}
 
 		if err != nil {
@@ -833,49 +1854,109 @@ func (c *Client) execute(req *Request) (*Response, error) {
 			resp.Err = err
 
 			// Check if we should retry
-			if attempt < maxAttempts-1 && c.shouldRetry(resp, err) {
-				time.Sleep(c.retryInterval)
+			if attempt < maxAttempts-1 && c.shouldRetry(retryCondition, resp, err, attempt+1, time.Since(retryStart)) && c.withinRetryBudget(retryStart, retryInterval) {
+				if waitErr := c.waitRetry(req.Context(), resp, err, attempt+1, retryInterval); waitErr != nil {
+					resp.Err = waitErr
+					lastErr = waitErr
+					break
+				}
 				continue
 			}
 			break
 		}
 
-		// Read response body
-		if httpResp.Body != nil {
+		// Read response body, or hand it to the caller unread when streaming
+		bytesReceived += responseHeaderSize(httpResp)
+		if req.stream {
+			resp.streamRecv = &atomic.Int64{}
+			resp.stream = &countingReader{ReadCloser: httpResp.Body, n: resp.streamRecv}
+		} else if req.writer != nil && httpResp.Body != nil && httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+			// Stream the body into the writer; a partial write is not retried
+			defer httpResp.Body.Close()
+			written, err := io.Copy(req.writer, httpResp.Body)
+			resp.size = written
+			bytesReceived += written
+			httpResp.Body = http.NoBody
+			if err != nil {
+				resp.Err = fmt.Errorf("failed to write response body: %w", err)
+				lastErr = resp.Err
+				break
+			}
+		} else if req.downloadPath != "" && httpResp.Body != nil && httpResp.StatusCode >= 200 && httpResp.StatusCode < 300 {
+			// Stream the body into the output file; a partial download is not retried
 			defer httpResp.Body.Close()
-			bodyBytes, err := io.ReadAll(httpResp.Body)
+			written, err := writeOutput(req, httpResp, resumeOffset)
+			resp.size = written
+			bytesReceived += written
+			httpResp.Body = http.NoBody
 			if err != nil {
+				resp.Err = fmt.Errorf("failed to save output: %w", err)
+				lastErr = resp.Err
+				break
+			}
+		} else if httpResp.Body != nil {
+			defer httpResp.Body.Close()
+			bodyBytes, err := c.readBody(httpResp.Body, httpResp.Uncompressed)
+			if err != nil {
+				err = c.classifyError(httpReq.Context(), startTime, err)
 				resp.Err = fmt.Errorf("failed to read response body: %w", err)
 				lastErr = resp.Err
-				if attempt < maxAttempts-1 && c.shouldRetry(resp, resp.Err) {
-					time.Sleep(c.retryInterval)
+				if c.keepPartialBody && !errors.Is(err, ErrBodyTooLarge) {
+					// Keep the bytes read before the failure for inspection
+					resp.body = bodyBytes
+					resp.size = int64(len(bodyBytes))
+					bytesReceived += resp.size
+					resp.setStatus(httpResp)
+				}
+				if !errors.Is(err, ErrBodyTooLarge) && attempt < maxAttempts-1 && c.shouldRetry(retryCondition, resp, resp.Err, attempt+1, time.Since(retryStart)) && c.withinRetryBudget(retryStart, retryInterval) {
+					if waitErr := c.waitRetry(req.Context(), resp, resp.Err, attempt+1, retryInterval); waitErr != nil {
+						resp.Err = waitErr
+						lastErr = waitErr
+						break
+					}
 					continue
 				}
 				break
 			}
 			resp.body = bodyBytes
 			resp.size = int64(len(bodyBytes))
+			bytesReceived += resp.size
+			// Replace the consumed body so resp.Response.Body stays readable
+			httpResp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 		}
 
 		// Copy status information
 		if httpResp != nil {
-			resp.StatusCode = httpResp.StatusCode
-			resp.Status = httpResp.Status
-			resp.Proto = httpResp.Proto
-			resp.ProtoMajor = httpResp.ProtoMajor
-			resp.ProtoMinor = httpResp.ProtoMinor
-			resp.Header = httpResp.Header
+			resp.setStatus(httpResp)
 		}
 
-		// Run after response middlewares
+		// Serve from cache on 304 Not Modified, otherwise refresh the cache
+		if cacheKey != "" {
+			if resp.StatusCode == http.StatusNotModified && cached != nil {
+				resp.body = append([]byte(nil), cached.Body...)
+				resp.size = int64(len(resp.body))
+				resp.StatusCode = http.StatusOK
+				resp.Status = "200 OK"
+				resp.Header = cached.Header.Clone()
+				resp.fromCache = true
+			} else {
+				c.storeCache(cacheKey, httpReq, resp)
+			}
+		}
+
+		// A resumed download of a file that is already complete succeeds
+		if resumeComplete(req, resp, resumeOffset) {
+			resp.body = nil
+			resp.size = 0
+			resp.StatusCode = http.StatusOK
+			resp.Status = "200 OK"
+		}
+
+		// Run after response middlewares in registration order, stopping at the first error
 		for _, middleware := range c.afterResponse {
 			if err := middleware(c, resp); err != nil {
 				resp.Err = fmt.Errorf("after response middleware error: %w", err)
 				lastErr = resp.Err
-				if attempt < maxAttempts-1 && c.shouldRetry(resp, resp.Err) {
-					time.Sleep(c.retryInterval)
-					continue
-				}
 				break
 			}
 		}
@@ -883,91 +1964,57 @@ func (c *Client) execute(req *Request) (*Response, error) {
 		// Unmarshal success/error results
 		if resp.Err == nil {
 			resp.state = c.resultChecker(resp)
-
-			if resp.state == SuccessState && req.successResult != nil {
-				if err := c.unmarshalResponse(resp, req.successResult); err != nil {
-					resp.Err = fmt.Errorf("failed to unmarshal success result: %w", err)
-				}
-			} else if resp.state == ErrorState {
-				if req.errorResult != nil {
-					c.unmarshalResponse(resp, req.errorResult)
-				} else if c.commonErrorResult != nil {
-					c.unmarshalResponse(resp, c.commonErrorResult)
-				}
-			}
+			c.bindResult(req, resp)
 		}
 
 		// Debug: Print response details
-		if c.debug {
-			c.debugResponse(resp)
+		if c.debugEnabled() {
+			c.debugResponse(resp, attempt+1, maxAttempts)
 		}
 
 		// Check if we should retry
-		if attempt < maxAttempts-1 && c.shouldRetry(resp, resp.Err) {
-			if c.debug {
-				log.Printf("[DEBUG] RETRY - Retrying in %v...", c.retryInterval)
+		if attempt < maxAttempts-1 && c.shouldRetry(retryCondition, resp, resp.Err, attempt+1, time.Since(retryStart)) && c.withinRetryBudget(retryStart, retryInterval) {
+			if resp.stream != nil {
+				resp.stream.Close()
+				resp.stream = nil
+			}
+			if waitErr := c.waitRetry(req.Context(), resp, resp.Err, attempt+1, retryInterval); waitErr != nil {
+				resp.Err = waitErr
+				lastErr = waitErr
+				break
 			}
-			time.Sleep(c.retryInterval)
 			continue
 		}
 
 		break
 	}
 
-	// Call error handler if there's an error
-	if resp != nil && resp.Err != nil && c.onError != nil {
-		c.onError(c, req, resp, resp.Err)
+	if resp != nil {
+		resp.history = append(history, resp.attemptInfo())
+		resp.bytesSent = bytesSent + bodyBytesSent.Load()
+		resp.bytesRecv = bytesReceived
 	}
 
-	if resp == nil && lastErr != nil {
-		return nil, lastErr
-	}
-
-	return resp, resp.Err
-}
-
-// debugRequest prints debug information for the request
-func (c *Client) debugRequest(req *http.Request, attempt, maxAttempts int) {
-	log.Printf("[DEBUG] REQUEST - Attempt: %d/%d, Method: %s, URL: %s", attempt, maxAttempts, req.Method, req.URL.String())
-
-	for key, values := range req.Header {
-		for _, value := range values {
-			log.Printf("[DEBUG] REQUEST Header - %s: %s", key, value)
+	// Debug: Report the final error
+	if c.debugEnabled() {
+		if resp != nil && resp.Err != nil {
+			c.debugError(req, resp.Err, attempts)
+		} else if resp == nil && lastErr != nil {
+			c.debugError(req, lastErr, attempts)
 		}
 	}
 
-	if req.Body != nil {
-		// Try to read body for debug (this won't consume the original body)
-		if req.GetBody != nil {
-			if body, err := req.GetBody(); err == nil {
-				if bodyBytes, err := io.ReadAll(body); err == nil && len(bodyBytes) > 0 {
-					bodyStr := string(bodyBytes)
-					if len(bodyStr) > 300 {
-						bodyStr = bodyStr[:300] + "...(truncated)"
-					}
-					log.Printf("[DEBUG] REQUEST Body - %s", bodyStr)
-				}
-				body.Close()
-			}
-		}
+	// Call error handler if there's an error
+	if resp != nil && resp.Err != nil && c.onError != nil {
+		c.onError(c, req, resp, resp.Err)
 	}
-} // debugResponse prints debug information for the response
-func (c *Client) debugResponse(resp *Response) {
-	log.Printf("[DEBUG] RESPONSE - Status: %s (%d), Duration: %v, Size: %d bytes",
-		resp.Status, resp.StatusCode, resp.Duration(), resp.Size())
 
-	for key, values := range resp.Header {
-		for _, value := range values {
-			log.Printf("[DEBUG] RESPONSE Header - %s: %s", key, value)
+	if resp == nil && lastErr != nil {
+		if c.onError != nil {
+			c.onError(c, req, nil, lastErr)
 		}
+		return nil, lastErr
 	}
 
-	if len(resp.body) > 0 {
-		// Limit body display to first 300 characters
-		bodyStr := string(resp.body)
-		if len(bodyStr) > 300 {
-			bodyStr = bodyStr[:300] + "...(truncated)"
-		}
-		log.Printf("[DEBUG] RESPONSE Body - %s", bodyStr)
-	}
+	return resp, resp.Err
 }
@@ -18,13 +18,16 @@ type Config struct {
 	AllowGetPayload   bool
 	RetryCount        int
 	RetryInterval     time.Duration
+	MaxBodySize       int64
 	TLSConfig         *tls.Config
 	Transport         http.RoundTripper
+	CookieJar         http.CookieJar
 	BeforeRequest     []RequestMiddleware
 	AfterResponse     []ResponseMiddleware
 	RetryCondition    RetryConditionFunc
 	ErrorHandler      ErrorHook
 	OnError           ErrorHook
+	OnRetry           RetryHook
 	CommonErrorResult interface{}
 	ResultChecker     func(*Response) ResultState
 }
@@ -0,0 +1,89 @@
+package cumi
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+// Read reads from the wrapped body and adds to the counter
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// wrapRequestBody wraps httpReq's body, and every body GetBody returns, with
+// wrap, so copies sent again (hedges, redirects) are measured too
+func wrapRequestBody(httpReq *http.Request, wrap func(body io.ReadCloser) io.ReadCloser) {
+	httpReq.Body = wrap(httpReq.Body)
+	if getBody := httpReq.GetBody; getBody != nil {
+		httpReq.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return wrap(body), nil
+		}
+	}
+}
+
+// countingWriter counts the bytes written to it
+type countingWriter int64
+
+// Write discards b and adds its length to the counter
+func (w *countingWriter) Write(b []byte) (int, error) {
+	*w += countingWriter(len(b))
+	return len(b), nil
+}
+
+// requestHeaderSize estimates the size of the request line and headers on the wire
+func requestHeaderSize(httpReq *http.Request) int64 {
+	host := httpReq.Host
+	if host == "" {
+		host = httpReq.URL.Host
+	}
+	n := int64(len(httpReq.Method) + 1 + len(httpReq.URL.RequestURI()) + len(" HTTP/1.1\r\n"))
+	n += int64(len("Host: ") + len(host) + len("\r\n"))
+	if httpReq.ContentLength > 0 && httpReq.Header.Get("Content-Length") == "" {
+		n += int64(len("Content-Length: ") + len(strconv.FormatInt(httpReq.ContentLength, 10)) + len("\r\n"))
+	}
+
+	var w countingWriter
+	httpReq.Header.Write(&w)
+	return n + int64(w) + int64(len("\r\n"))
+}
+
+// responseHeaderSize estimates the size of the status line and headers on the wire
+func responseHeaderSize(httpResp *http.Response) int64 {
+	n := int64(len(httpResp.Proto) + 1 + len(httpResp.Status) + len("\r\n"))
+
+	var w countingWriter
+	httpResp.Header.Write(&w)
+	return n + int64(w) + int64(len("\r\n"))
+}
+
+// BytesSent returns the bytes sent for this request across all attempts,
+// counting request bodies exactly and estimating the request line and headers
+func (r *Response) BytesSent() int64 {
+	return r.bytesSent
+}
+
+// BytesReceived returns the bytes received for this request across all attempts,
+// counting bodies as read and estimating status lines and headers. Bodies
+// decompressed by the transport are counted after decompression. For streamed
+// responses the count grows as the body is read.
+func (r *Response) BytesReceived() int64 {
+	n := r.bytesRecv
+	if r.streamRecv != nil {
+		n += r.streamRecv.Load()
+	}
+	return n
+}
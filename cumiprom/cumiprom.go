@@ -0,0 +1,105 @@
+// Package cumiprom exports Prometheus metrics for cumi clients.
+//
+// It lives in its own module so that users who don't need Prometheus are not
+// forced to depend on client_golang.
+package cumiprom
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sofyan48/cumi"
+)
+
+// Collector records request rate, errors and duration for cumi clients
+type Collector struct {
+	duration *prometheus.HistogramVec
+	requests *prometheus.CounterVec
+	inFlight prometheus.Gauge
+}
+
+// NewCollector creates a collector with metrics in the "cumi" namespace:
+// request_duration_seconds (by method), requests_total (by method and status)
+// and requests_in_flight
+func NewCollector() *Collector {
+	return &Collector{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cumi",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of logical requests, including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cumi",
+			Name:      "requests_total",
+			Help:      "Logical requests by method and status code, or \"error\" when no response was received.",
+		}, []string{"method", "status"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "cumi",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests currently waiting for a response.",
+		}),
+	}
+}
+
+// Register creates a collector, registers it with registerer and installs it
+// on client. It replaces the client's metrics hook.
+func Register(client *cumi.Client, registerer prometheus.Registerer) (*Collector, error) {
+	collector := NewCollector()
+	if err := registerer.Register(collector); err != nil {
+		return nil, err
+	}
+	collector.Instrument(client)
+	return collector, nil
+}
+
+// Instrument installs the collector on client as its metrics hook and as a
+// transport wrapper tracking in-flight requests
+func (c *Collector) Instrument(client *cumi.Client) *cumi.Client {
+	return client.
+		SetMetricsHook(c.Observe).
+		WrapTransport(c.wrapTransport)
+}
+
+// Observe records a completed request. It can be called from a custom
+// metrics hook that also does other work.
+func (c *Collector) Observe(m cumi.RequestMetrics) {
+	status := "error"
+	if m.StatusCode > 0 {
+		status = strconv.Itoa(m.StatusCode)
+	}
+	c.duration.WithLabelValues(m.Method).Observe(m.Duration.Seconds())
+	c.requests.WithLabelValues(m.Method, status).Inc()
+}
+
+// wrapTransport counts requests in flight until their response headers arrive
+func (c *Collector) wrapTransport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		c.inFlight.Inc()
+		defer c.inFlight.Dec()
+		return next.RoundTrip(req)
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.duration.Describe(ch)
+	c.requests.Describe(ch)
+	c.inFlight.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.duration.Collect(ch)
+	c.requests.Collect(ch)
+	c.inFlight.Collect(ch)
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
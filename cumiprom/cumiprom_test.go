@@ -0,0 +1,50 @@
+package cumiprom
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sofyan48/cumi"
+)
+
+func TestRegister(t *testing.T) {
+	var inFlight float64
+	var collector *Collector
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight = testutil.ToFloat64(collector.inFlight)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	client := cumi.NewClient()
+	collector, err := Register(client, registry)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	client.Get(server.URL).Execute()
+	client.Get(server.URL + "/missing").Execute()
+
+	if inFlight != 1 || testutil.ToFloat64(collector.inFlight) != 0 {
+		t.Errorf("Expected one request in flight during the call, got %v", inFlight)
+	}
+	if got := testutil.ToFloat64(collector.requests.WithLabelValues("GET", "200")); got != 1 {
+		t.Errorf("Expected one 200 request, got %v", got)
+	}
+	if got := testutil.ToFloat64(collector.requests.WithLabelValues("GET", "404")); got != 1 {
+		t.Errorf("Expected one 404 request, got %v", got)
+	}
+	if got := testutil.CollectAndCount(registry, "cumi_request_duration_seconds"); got != 1 {
+		t.Errorf("Expected one duration series, got %d", got)
+	}
+
+	if _, err := Register(cumi.NewClient(), registry); err == nil {
+		t.Errorf("Expected duplicate registration to fail")
+	}
+}
@@ -0,0 +1,191 @@
+// Package cumitest provides helpers for testing code that uses cumi clients.
+//
+// NewClient returns a client whose requests are served by canned handlers on
+// an httptest.Server, and Recorder captures the requests a client sends.
+package cumitest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/sofyan48/cumi"
+)
+
+// Handler serves a canned response for a route
+type Handler func(w http.ResponseWriter, r *http.Request)
+
+// JSON returns a handler that responds with status and body encoded as JSON
+func JSON(status int, body string) Handler {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// Server is an httptest.Server serving canned routes, with a recorder for the
+// requests sent by its client
+type Server struct {
+	*httptest.Server
+	Recorder *Recorder
+}
+
+// NewServer starts a server for routes. Keys use http.ServeMux patterns such
+// as "GET /users/{id}" or "/health"; unmatched requests get a 404.
+func NewServer(routes map[string]Handler) *Server {
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	return &Server{
+		Server:   httptest.NewServer(mux),
+		Recorder: &Recorder{},
+	}
+}
+
+// Client returns a cumi client with the server as its base URL. Requests it
+// sends are captured by the server's recorder.
+func (s *Server) Client() *cumi.Client {
+	return cumi.NewClient().
+		SetBaseURL(s.URL).
+		WrapTransport(s.Recorder.Wrap)
+}
+
+// NewClient starts a server for routes and returns a client for it. The server
+// is closed when the test finishes.
+func NewClient(t testing.TB, routes map[string]Handler) *cumi.Client {
+	t.Helper()
+	server := NewServer(routes)
+	t.Cleanup(server.Close)
+	return server.Client()
+}
+
+// RecordedRequest is a request captured by a Recorder
+type RecordedRequest struct {
+	Method     string
+	URL        string
+	Header     http.Header
+	Body       []byte
+	StatusCode int
+	Err        error
+}
+
+// Recorder is a RoundTripper middleware that captures every request sent
+// through it, including redirects and retries
+type Recorder struct {
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// Wrap returns a RoundTripper that records requests before passing them to
+// next. It can be used with cumi.Client.WrapTransport. Bodies are read from
+// GetBody when it is set; otherwise they are recorded as far as next had read
+// them when it returned.
+func (rec *Recorder) Wrap(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		recorded := RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+		}
+		var tee *teeBody
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody != nil {
+				body, err := readBody(req.GetBody)
+				if err != nil {
+					req.Body.Close()
+					return nil, err
+				}
+				recorded.Body = body
+			} else {
+				// Copy the body as next reads it, leaving the caller's request alone
+				tee = &teeBody{ReadCloser: req.Body}
+				req = req.Clone(req.Context())
+				req.Body = tee
+			}
+		}
+
+		resp, err := next.RoundTrip(req)
+		if tee != nil {
+			recorded.Body = tee.bytes()
+		}
+		recorded.Err = err
+		if resp != nil {
+			recorded.StatusCode = resp.StatusCode
+		}
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, recorded)
+		rec.mu.Unlock()
+		return resp, err
+	})
+}
+
+// readBody reads a fresh copy of a request body
+func readBody(getBody func() (io.ReadCloser, error)) ([]byte, error) {
+	body, err := getBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// teeBody copies what is read from a request body
+type teeBody struct {
+	io.ReadCloser
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Read reads from the body and keeps a copy
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	t.mu.Lock()
+	t.buf.Write(p[:n])
+	t.mu.Unlock()
+	return n, err
+}
+
+// bytes returns a copy of what has been read so far
+func (t *teeBody) bytes() []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return bytes.Clone(t.buf.Bytes())
+}
+
+// Requests returns the recorded requests in the order they completed
+func (rec *Recorder) Requests() []RecordedRequest {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]RecordedRequest(nil), rec.requests...)
+}
+
+// Last returns the most recently recorded request
+func (rec *Recorder) Last() (RecordedRequest, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.requests) == 0 {
+		return RecordedRequest{}, false
+	}
+	return rec.requests[len(rec.requests)-1], true
+}
+
+// Reset discards the recorded requests
+func (rec *Recorder) Reset() {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.requests = nil
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
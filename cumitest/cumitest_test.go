@@ -0,0 +1,82 @@
+package cumitest
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNewClient(t *testing.T) {
+	server := NewServer(map[string]Handler{
+		"GET /users/{id}": JSON(http.StatusOK, `{"name":"John"}`),
+		"POST /users":     JSON(http.StatusCreated, `{"name":"Jane"}`),
+	})
+	defer server.Close()
+	client := server.Client()
+
+	var user struct{ Name string }
+	resp, err := client.Get("/users/1").SetResult(&user).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || user.Name != "John" {
+		t.Errorf("Expected canned user, got %d %+v", resp.StatusCode, user)
+	}
+
+	resp, err = client.Post("/users").SetBodyJSON(map[string]string{"name": "Jane"}).Execute()
+	if err != nil || resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201, got %v (err=%v)", resp, err)
+	}
+
+	resp, err = client.Delete("/users/1").Execute()
+	if err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for an unrouted method, got %v (err=%v)", resp, err)
+	}
+
+	requests := server.Recorder.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 recorded requests, got %d", len(requests))
+	}
+	if requests[1].Method != http.MethodPost || string(requests[1].Body) != `{"name":"Jane"}` || requests[1].StatusCode != http.StatusCreated {
+		t.Errorf("Unexpected recorded request %+v", requests[1])
+	}
+}
+
+func TestNewClientClosesServer(t *testing.T) {
+	client := NewClient(t, map[string]Handler{
+		"/health": JSON(http.StatusOK, `{"status":"ok"}`),
+	})
+	resp, err := client.Get("/health").Execute()
+	if err != nil || resp.String() != `{"status":"ok"}` {
+		t.Errorf("Expected canned health response, got %v (err=%v)", resp, err)
+	}
+}
+
+func TestRecorderLeavesRequestAlone(t *testing.T) {
+	var rec Recorder
+	transport := rec.Wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		req.Body.Close()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}))
+
+	// With GetBody, and with a body that can only be read once
+	replayable, _ := http.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("replayable"))
+	once, _ := http.NewRequest(http.MethodPost, "http://example.com/", io.NopCloser(strings.NewReader("once")))
+	for _, req := range []*http.Request{replayable, once} {
+		body := req.Body
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if req.Body != body {
+			t.Errorf("Expected the request body not to be replaced")
+		}
+	}
+
+	requests := rec.Requests()
+	if len(requests) != 2 || string(requests[0].Body) != "replayable" || string(requests[1].Body) != "once" {
+		t.Errorf("Unexpected recorded requests %+v", requests)
+	}
+}
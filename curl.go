@@ -0,0 +1,333 @@
+package cumi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// curlIgnoredFlags are curl options without an argument that don't change the
+// request itself
+var curlIgnoredFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true,
+	"-v": true, "--verbose": true, "-i": true, "--include": true,
+	"-L": true, "--location": true, "-k": true, "--insecure": true,
+	"--compressed": true, "-f": true, "--fail": true, "-N": true, "--no-buffer": true,
+}
+
+// FromCurl builds a request on DefaultClient from a curl command line, such
+// as one copied from a browser's developer tools. It supports the method
+// (-X, -I, -G), headers (-H, -A, -e, -b), data (-d, --data-raw,
+// --data-binary, --data-urlencode, including @file), basic auth (-u) and the
+// URL (--url or a bare argument). Short options may be combined, as in -sSL.
+// Options that only affect curl's output are ignored; any other option, and
+// data read from stdin, is an error.
+func FromCurl(command string) (*Request, error) {
+	args, err := splitCurlArgs(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	req := DefaultClient.Http()
+	var method, rawURL string
+	var data []string
+	var head, get bool
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			rawURL = arg
+			continue
+		}
+		// Split clusters of short options, as in -sSL or -sXPOST
+		if len(arg) > 2 && arg[1] != '-' && (curlIgnoredFlags[arg[:2]] || arg[:2] == "-I" || arg[:2] == "-G") {
+			rest := append([]string{arg[:2], "-" + arg[2:]}, args[i+1:]...)
+			args = append(args[:i], rest...)
+			arg = args[i]
+		}
+		if curlIgnoredFlags[arg] {
+			continue
+		}
+		switch arg {
+		case "-I", "--head":
+			head = true
+			continue
+		case "-G", "--get":
+			get = true
+			continue
+		}
+
+		// Short options may carry their value inline, as in -XPOST
+		name, value, inline := arg, "", false
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name, value, inline = arg[:2], arg[2:], true
+		}
+		if !inline {
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("invalid curl command: option %s requires a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "-X", "--request":
+			method = strings.ToUpper(value)
+		case "--url":
+			rawURL = value
+		case "-H", "--header":
+			key, val, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid curl command: malformed header %q", value)
+			}
+			req.headers.Add(strings.TrimSpace(key), strings.TrimSpace(val))
+		case "-A", "--user-agent":
+			req.headers.Set("User-Agent", value)
+		case "-e", "--referer":
+			req.headers.Set("Referer", value)
+		case "-b", "--cookie":
+			req.headers.Add("Cookie", value)
+		case "-u", "--user":
+			username, password, _ := strings.Cut(value, ":")
+			req.SetBasicAuth(username, password)
+		case "-d", "--data", "--data-binary", "--data-ascii":
+			if file, ok := strings.CutPrefix(value, "@"); ok {
+				content, err := readCurlFile(file)
+				if err != nil {
+					return nil, err
+				}
+				// Like curl, only --data-binary keeps line breaks
+				if name != "--data-binary" {
+					content = strings.NewReplacer("\r", "", "\n", "").Replace(content)
+				}
+				value = content
+			}
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--data-urlencode":
+			encoded, err := curlURLEncode(value)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, encoded)
+		default:
+			return nil, fmt.Errorf("invalid curl command: unsupported option %s", arg)
+		}
+	}
+
+	if rawURL == "" {
+		return nil, errors.New("invalid curl command: missing URL")
+	}
+	req.url = rawURL
+
+	switch {
+	case method != "":
+		req.method = method
+	case head:
+		req.method = http.MethodHead
+	case len(data) > 0 && !get:
+		req.method = http.MethodPost
+	default:
+		req.method = http.MethodGet
+	}
+
+	if len(data) > 0 {
+		joined := strings.Join(data, "&")
+		if get {
+			query, err := url.ParseQuery(joined)
+			if err != nil {
+				return nil, fmt.Errorf("invalid curl command: %w", err)
+			}
+			req.SetQueryParamsFromValues(query)
+		} else {
+			req.SetBodyString(joined)
+			if req.headers.Get("Content-Type") == "" {
+				req.headers.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+		}
+	}
+	return req, nil
+}
+
+// curlURLEncode encodes a --data-urlencode value the way curl does: the part
+// after the first "=" is encoded and a leading name is kept as is. With "@"
+// instead of "=", the content is read from the named file.
+func curlURLEncode(value string) (string, error) {
+	i := strings.IndexAny(value, "=@")
+	if i < 0 {
+		return url.QueryEscape(value), nil
+	}
+	name, content := value[:i], value[i+1:]
+	if value[i] == '@' {
+		var err error
+		if content, err = readCurlFile(content); err != nil {
+			return "", err
+		}
+	}
+	if name == "" {
+		return url.QueryEscape(content), nil
+	}
+	return name + "=" + url.QueryEscape(content), nil
+}
+
+// readCurlFile reads the file named by an @file data argument
+func readCurlFile(path string) (string, error) {
+	if path == "-" {
+		return "", errors.New("invalid curl command: reading data from stdin is not supported")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid curl command: %w", err)
+	}
+	return string(content), nil
+}
+
+// splitCurlArgs splits a shell command line into arguments, handling single
+// and double quotes, backslash escapes and line continuations
+func splitCurlArgs(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(ch)
+			}
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+			} else if ch == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			} else {
+				current.WriteRune(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' || runes[i] == '\r' {
+					// Line continuation
+					if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+						i++
+					}
+					continue
+				}
+				current.WriteRune(runes[i])
+				inArg = true
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(ch)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("invalid curl command: unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// ToCurl renders the request as a curl command, as prepared for sending: the
+// resolved URL, headers, auth, cookies and body. Streamed bodies that can't be
+// read without consuming them are shown as read from stdin. It returns an
+// empty string if the request can't be prepared.
+func (r *Request) ToCurl() string {
+	return r.toCurl(false)
+}
+
+// ToCurlRedacted is like ToCurl, but masks the headers and body the way debug
+// output does (see SetRedactHeaders and SetBodyRedactor) along with any
+// password in the URL, so the command can be shared safely
+func (r *Request) ToCurlRedacted() string {
+	return r.toCurl(true)
+}
+
+// toCurl renders the request as a curl command, optionally redacted
+func (r *Request) toCurl(redact bool) string {
+	prepared := *r
+	httpReq, err := r.client.prepareRequest(&prepared)
+	if err != nil {
+		return ""
+	}
+
+	header := httpReq.Header
+	rawURL := httpReq.URL.String()
+	if redact {
+		header = r.client.redactHeaderValues(header)
+		rawURL = httpReq.URL.Redacted()
+	}
+
+	var b strings.Builder
+	b.WriteString("curl")
+	switch httpReq.Method {
+	case http.MethodGet:
+	case http.MethodHead:
+		b.WriteString(" --head")
+	default:
+		b.WriteString(" -X " + httpReq.Method)
+	}
+	b.WriteString(" " + shellQuote(rawURL))
+
+	if httpReq.Host != "" && httpReq.Host != httpReq.URL.Host {
+		b.WriteString(" -H " + shellQuote("Host: "+httpReq.Host))
+	}
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			b.WriteString(" -H " + shellQuote(k+": "+v))
+		}
+	}
+
+	if httpReq.Body != nil && httpReq.Body != http.NoBody {
+		if httpReq.GetBody == nil {
+			b.WriteString(" --data-binary @-")
+		} else if body, err := httpReq.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			if redact {
+				data = r.client.redactBody(data)
+			}
+			b.WriteString(" --data-binary " + shellQuote(string(data)))
+		}
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
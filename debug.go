@@ -0,0 +1,178 @@
+package cumi
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// defaultDebugBodyLimit is the number of body characters logged in debug output
+const defaultDebugBodyLimit = 300
+
+// DebugEventType identifies the kind of a DebugEvent
+type DebugEventType string
+
+const (
+	DebugEventRequest  DebugEventType = "request"
+	DebugEventResponse DebugEventType = "response"
+	DebugEventRetry    DebugEventType = "retry"
+	DebugEventError    DebugEventType = "error"
+)
+
+// DebugEvent describes a single step of a request. Headers and bodies are
+// already redacted according to the client's redaction settings.
+type DebugEvent struct {
+	Type        DebugEventType
+	Method      string
+	URL         string
+	Header      http.Header
+	Body        []byte
+	Status      string
+	StatusCode  int
+	Duration    time.Duration
+	Size        int64
+	Attempt     int
+	MaxAttempts int
+	RetryIn     time.Duration
+	Err         error
+}
+
+// DebugHook receives structured debug events
+type DebugHook func(DebugEvent)
+
+// debugEnabled reports whether debug events should be produced
+func (c *Client) debugEnabled() bool {
+	return c.debug || c.debugHook != nil
+}
+
+// emitDebug sends the event to the debug hook, or logs it when no hook is set
+func (c *Client) emitDebug(event DebugEvent) {
+	if c.debugHook != nil {
+		c.debugHook(event)
+		return
+	}
+	logDebugEvent(event, c.debugBodyLimit)
+}
+
+// debugRequest reports the request about to be sent
+func (c *Client) debugRequest(req *http.Request, attempt, maxAttempts int) {
+	event := DebugEvent{
+		Type:        DebugEventRequest,
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		Header:      c.redactHeaderValues(req.Header),
+		Attempt:     attempt,
+		MaxAttempts: maxAttempts,
+	}
+
+	// Read the body through GetBody so the original body is not consumed
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			if bodyBytes, err := io.ReadAll(body); err == nil && len(bodyBytes) > 0 {
+				event.Body = c.redactBody(bodyBytes)
+			}
+			body.Close()
+		}
+	}
+
+	c.emitDebug(event)
+}
+
+// debugResponse reports the received response
+func (c *Client) debugResponse(resp *Response, attempt, maxAttempts int) {
+	event := DebugEvent{
+		Type:        DebugEventResponse,
+		Header:      c.redactHeaderValues(resp.Header),
+		Status:      resp.Status,
+		StatusCode:  resp.StatusCode,
+		Duration:    resp.Duration(),
+		Size:        resp.Size(),
+		Attempt:     attempt,
+		MaxAttempts: maxAttempts,
+	}
+	if resp.Request != nil {
+		event.Method = resp.Request.method
+	}
+	if resp.Response != nil && resp.Response.Request != nil {
+		event.URL = resp.Response.Request.URL.String()
+	}
+	if len(resp.body) > 0 {
+		event.Body = c.redactBody(resp.body)
+	}
+
+	c.emitDebug(event)
+}
+
+// debugRetry reports that the attempt will be retried after interval
+func (c *Client) debugRetry(resp *Response, err error, attempt int, interval time.Duration) {
+	event := DebugEvent{
+		Type:    DebugEventRetry,
+		Attempt: attempt,
+		RetryIn: interval,
+		Err:     err,
+	}
+	if resp != nil {
+		event.Status = resp.Status
+		event.StatusCode = resp.StatusCode
+	}
+
+	c.emitDebug(event)
+}
+
+// debugError reports the error the request finished with
+func (c *Client) debugError(req *Request, err error, attempts int) {
+	c.emitDebug(DebugEvent{
+		Type:    DebugEventError,
+		Method:  req.method,
+		URL:     req.url,
+		Attempt: attempts,
+		Err:     err,
+	})
+}
+
+// redactHeaderValues returns a copy of header with sensitive values redacted
+func (c *Client) redactHeaderValues(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			redacted[key] = append(redacted[key], c.redactHeader(key, value))
+		}
+	}
+	return redacted
+}
+
+// logDebugEvent prints the event using the standard logger, truncating
+// bodies to bodyLimit characters unless it is zero
+func logDebugEvent(event DebugEvent, bodyLimit int) {
+	switch event.Type {
+	case DebugEventRequest:
+		log.Printf("[DEBUG] REQUEST - Attempt: %d/%d, Method: %s, URL: %s", event.Attempt, event.MaxAttempts, event.Method, event.URL)
+		logDebugHeaderBody("REQUEST", event, bodyLimit)
+	case DebugEventResponse:
+		log.Printf("[DEBUG] RESPONSE - Status: %s (%d), Duration: %v, Size: %d bytes",
+			event.Status, event.StatusCode, event.Duration, event.Size)
+		logDebugHeaderBody("RESPONSE", event, bodyLimit)
+	case DebugEventRetry:
+		log.Printf("[DEBUG] RETRY - Retrying in %v...", event.RetryIn)
+	case DebugEventError:
+		log.Printf("[DEBUG] ERROR - Method: %s, URL: %s, Attempts: %d, Error: %v", event.Method, event.URL, event.Attempt, event.Err)
+	}
+}
+
+// logDebugHeaderBody prints the event headers and a truncated body
+func logDebugHeaderBody(prefix string, event DebugEvent, bodyLimit int) {
+	for key, values := range event.Header {
+		for _, value := range values {
+			log.Printf("[DEBUG] %s Header - %s: %s", prefix, key, value)
+		}
+	}
+
+	if len(event.Body) > 0 {
+		bodyStr := string(event.Body)
+		if bodyLimit > 0 && len(bodyStr) > bodyLimit {
+			bodyStr = bodyStr[:bodyLimit] + "...(truncated)"
+		}
+		log.Printf("[DEBUG] %s Body - %s", prefix, bodyStr)
+	}
+}
@@ -0,0 +1,35 @@
+package cumi
+
+// DefaultClient is the shared client used by the package-level request functions.
+// Reusing it keeps one transport and connection pool for the whole process.
+var DefaultClient = NewClient()
+
+// Get sends a GET request using DefaultClient
+func Get(url string) (*Response, error) {
+	return DefaultClient.Get(url).Execute()
+}
+
+// Post sends a POST request with the given body using DefaultClient
+func Post(url string, body interface{}) (*Response, error) {
+	return DefaultClient.Post(url).SetBody(body).Execute()
+}
+
+// Put sends a PUT request with the given body using DefaultClient
+func Put(url string, body interface{}) (*Response, error) {
+	return DefaultClient.Put(url).SetBody(body).Execute()
+}
+
+// Patch sends a PATCH request with the given body using DefaultClient
+func Patch(url string, body interface{}) (*Response, error) {
+	return DefaultClient.Patch(url).SetBody(body).Execute()
+}
+
+// Delete sends a DELETE request using DefaultClient
+func Delete(url string) (*Response, error) {
+	return DefaultClient.Delete(url).Execute()
+}
+
+// Head sends a HEAD request using DefaultClient
+func Head(url string) (*Response, error) {
+	return DefaultClient.Head(url).Execute()
+}
@@ -0,0 +1,85 @@
+package cumi
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// SetHostOverride connects to addr whenever a request targets host, without
+// changing the URL, Host header or TLS server name. addr may omit the port,
+// in which case the request's port is kept.
+func (c *Client) SetHostOverride(host, addr string) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hostOverrides == nil {
+		c.hostOverrides = make(map[string]string)
+	}
+	c.hostOverrides[host] = addr
+	c.installDialer()
+	return c
+}
+
+// SetResolver sets the DNS resolver used when dialing connections
+func (c *Client) SetResolver(resolver *net.Resolver) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.resolver = resolver
+	c.installDialer()
+	return c
+}
+
+// SetDialTimeout limits how long establishing a TCP connection may take,
+// independently of the overall request timeout. The default is 30 seconds.
+func (c *Client) SetDialTimeout(d time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dialTimeout = d
+	c.installDialer()
+	return c
+}
+
+// SetTLSHandshakeTimeout limits how long the TLS handshake may take,
+// independently of the overall request timeout
+func (c *Client) SetTLSHandshakeTimeout(d time.Duration) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if transport, ok := c.httpTransport(); ok {
+		transport.TLSHandshakeTimeout = d
+	}
+	return c
+}
+
+// installDialer points the transport at the client's dialer. Callers must hold c.mu.
+func (c *Client) installDialer() {
+	if transport, ok := c.httpTransport(); ok {
+		transport.DialContext = c.dialContext
+	}
+}
+
+// dialContext dials address, applying host overrides, the custom resolver and the dial timeout
+func (c *Client) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	c.mu.RLock()
+	resolver := c.resolver
+	timeout := c.dialTimeout
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		if override, ok := c.hostOverrides[host]; ok {
+			if _, _, err := net.SplitHostPort(override); err == nil {
+				address = override
+			} else {
+				address = net.JoinHostPort(override, port)
+			}
+		}
+	}
+	c.mu.RUnlock()
+
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Resolver:  resolver,
+	}
+	return dialer.DialContext(ctx, network, address)
+}
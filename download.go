@@ -0,0 +1,93 @@
+package cumi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyResumeRange adds a Range header for the existing partial output file
+// and returns the offset the download resumes from.
+func applyResumeRange(httpReq *http.Request, req *Request) (int64, error) {
+	if req.downloadPath == "" || !req.resumeOutput {
+		return 0, nil
+	}
+	info, err := os.Stat(req.downloadPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if info.Size() > 0 {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", info.Size()))
+	}
+	return info.Size(), nil
+}
+
+// writeOutput streams a successful response body into the request's output file
+// and returns the number of bytes written. A 206 response resuming at offset is
+// appended, a 200 response replaces the file.
+func writeOutput(req *Request, httpResp *http.Response, offset int64) (int64, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case httpResp.StatusCode == http.StatusPartialContent && offset > 0:
+		start, err := parseContentRangeStart(httpResp.Header.Get("Content-Range"))
+		if err != nil {
+			return 0, err
+		}
+		if start != offset {
+			return 0, fmt.Errorf("content range starts at %d, expected %d", start, offset)
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case httpResp.StatusCode == http.StatusPartialContent:
+		return 0, fmt.Errorf("unexpected partial content for a non-resumed download")
+	}
+
+	f, err := os.OpenFile(req.downloadPath, flags, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output file: %w", err)
+	}
+	written, err := io.Copy(f, httpResp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return written, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return written, nil
+}
+
+// resumeComplete reports whether a 416 response to a resumed download means
+// the output file already holds the whole body ("bytes */total" with total == offset)
+func resumeComplete(req *Request, resp *Response, offset int64) bool {
+	if !req.resumeOutput || offset == 0 || resp.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+		return false
+	}
+	total, ok := strings.CutPrefix(resp.Header.Get("Content-Range"), "bytes */")
+	if !ok {
+		return false
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	return err == nil && n == offset
+}
+
+// parseContentRangeStart returns the first byte position of a "bytes start-end/total" header
+func parseContentRangeStart(contentRange string) (int64, error) {
+	value, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	start, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	return n, nil
+}
@@ -174,5 +174,6 @@ func safeRequestLevelExample() {
 // ✅ DO:
 // - Configure client BEFORE spawning goroutines (for shared config)
 // - Use client.Clone() to create independent copies for each goroutine
+// - Use client.CloneWithCookies() when each copy needs the parent's session cookies
 // - Use request-level SetHeader(), SetQueryParam() for per-request config
 // - Use sync.Mutex if you really need to modify shared client concurrently (advanced)
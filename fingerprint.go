@@ -0,0 +1,72 @@
+package cumi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// Fingerprint returns a stable SHA-256 hash of the method, resolved URL,
+// headers and body, suitable as a cache or deduplication key. It returns an
+// empty string when the request cannot be built. Bodies that cannot be read
+// twice (plain io.Readers) are left out. Auth is hashed from the request's
+// credentials instead of being applied, so the client token func is not
+// called and AWS SigV4 signing time does not change the result. Generated
+// request IDs are ignored for the same reason.
+func (r *Request) Fingerprint() string {
+	unsigned := *r
+	unsigned.noAuth = true
+	httpReq, err := r.client.prepareRequest(&unsigned)
+	if err != nil {
+		return ""
+	}
+
+	h := sha256.New()
+	write := func(s string) {
+		io.WriteString(h, s)
+		h.Write([]byte{0})
+	}
+
+	write(httpReq.Method)
+	write(httpReq.URL.String())
+
+	// Leave out the generated request ID so the result stays stable
+	r.client.mu.RLock()
+	requestIDHeader := http.CanonicalHeaderKey(r.client.requestIDHeader)
+	r.client.mu.RUnlock()
+
+	keys := make([]string, 0, len(httpReq.Header))
+	for k := range httpReq.Header {
+		if k != requestIDHeader {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		write(k)
+		for _, v := range httpReq.Header[k] {
+			write(v)
+		}
+	}
+
+	// Host auth and the token func are the same for every request to the URL
+	write(r.basicAuth.username)
+	write(r.basicAuth.password)
+	write(r.bearerToken)
+	if r.awsSigV4 != nil {
+		write(r.awsSigV4.accessKey)
+		write(r.awsSigV4.region)
+		write(r.awsSigV4.service)
+	}
+
+	if httpReq.GetBody != nil {
+		if body, err := httpReq.GetBody(); err == nil {
+			io.Copy(h, body)
+			body.Close()
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,38 @@
+package cumi
+
+import (
+	"net/url"
+	"reflect"
+)
+
+// SetFormDataFromStruct adds form data encoded from a struct. See encodeFormStruct
+// for the supported tags.
+func (r *Request) SetFormDataFromStruct(v interface{}) *Request {
+	values := make(url.Values)
+	if err := encodeFormStruct(values, v); err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return r
+	}
+	return r.SetFormDataFromValues(values)
+}
+
+// formEncoder encodes structs as form data. Field names come from the `form`
+// tag, falling back to the field name, and slices of scalars are encoded as key[].
+var formEncoder = &structEncoder{source: "form data", fieldOpts: formFieldOptions, sliceSuffix: "[]"}
+
+// encodeFormStruct reflects over a struct and adds its fields to values.
+// See structEncoder for how values are encoded.
+func encodeFormStruct(values url.Values, v interface{}) error {
+	return formEncoder.encode(values, v)
+}
+
+// formFieldOptions resolves the form key and options for a struct field from its tags
+func formFieldOptions(field reflect.StructField) (opts fieldOptions, skip bool) {
+	tag := field.Tag.Get("form")
+	if tag == "-" {
+		return opts, true
+	}
+	return parseFieldTag(field, tag), false
+}
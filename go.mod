@@ -2,12 +2,16 @@ module github.com/sofyan48/cumi
 
 go 1.25.0
 
-require go.opentelemetry.io/otel/trace v1.38.0
+require (
+	go.opentelemetry.io/otel v1.38.0
+	go.opentelemetry.io/otel/trace v1.38.0
+	golang.org/x/sync v0.19.0
+	google.golang.org/protobuf v1.36.12
+)
 
 require (
 	github.com/go-logr/logr v1.4.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
-	go.opentelemetry.io/otel v1.38.0
 	go.opentelemetry.io/otel/metric v1.38.0 // indirect
 )
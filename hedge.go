@@ -0,0 +1,136 @@
+package cumi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// EnableHedging sends up to maxExtra additional copies of an idempotent
+// request when no response has arrived after delay, spacing them by delay.
+// The first response wins and the other attempts are cancelled. Unlike a
+// retry, the original attempt keeps running when a hedge starts. Hedges go
+// through the same transport, including WrapTransport middleware such as rate
+// limiters. Requests whose body cannot be replayed are never hedged.
+func (c *Client) EnableHedging(delay time.Duration, maxExtra int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hedgeDelay = delay
+	c.hedgeMax = maxExtra
+	return c
+}
+
+// DisableHedging sends a single copy of every request (the default)
+func (c *Client) DisableHedging() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hedgeDelay = 0
+	c.hedgeMax = 0
+	return c
+}
+
+// hedgeResult is the outcome of one hedged attempt
+type hedgeResult struct {
+	index int
+	resp  *http.Response
+	err   error
+}
+
+// send sends httpReq, hedging it when enabled and the request is idempotent
+func (c *Client) send(req *Request, httpReq *http.Request) (*http.Response, error) {
+	client := c.httpClientFor(req)
+
+	c.mu.RLock()
+	delay, maxExtra := c.hedgeDelay, c.hedgeMax
+	c.mu.RUnlock()
+
+	replayable := httpReq.Body == nil || httpReq.Body == http.NoBody || httpReq.GetBody != nil
+	if delay <= 0 || maxExtra <= 0 || !isIdempotent(httpReq.Method) || !replayable {
+		return client.Do(httpReq)
+	}
+	return doHedged(client, httpReq, delay, maxExtra)
+}
+
+// doHedged runs the hedging loop for httpReq
+func doHedged(client *http.Client, httpReq *http.Request, delay time.Duration, maxExtra int) (*http.Response, error) {
+	results := make(chan hedgeResult, maxExtra+1)
+	var cancels []context.CancelFunc
+
+	launch := func() bool {
+		ctx, cancel := context.WithCancel(httpReq.Context())
+		attemptReq := httpReq.WithContext(ctx)
+		if len(cancels) > 0 {
+			// Hedges need their own copy of the body
+			attemptReq = httpReq.Clone(ctx)
+			if httpReq.GetBody != nil {
+				body, err := httpReq.GetBody()
+				if err != nil {
+					cancel()
+					return false
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := client.Do(attemptReq)
+			results <- hedgeResult{index: index, resp: resp, err: err}
+		}()
+		return true
+	}
+
+	launch()
+	inFlight := 1
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case result := <-results:
+			inFlight--
+			if result.err != nil {
+				cancels[result.index]()
+				lastErr = result.err
+				if inFlight == 0 {
+					return nil, lastErr
+				}
+				continue
+			}
+
+			// Cancel the losers and discard their responses in the background
+			for i, cancel := range cancels {
+				if i != result.index {
+					cancel()
+				}
+			}
+			go func(pending int) {
+				for ; pending > 0; pending-- {
+					if loser := <-results; loser.resp != nil {
+						loser.resp.Body.Close()
+					}
+				}
+			}(inFlight)
+
+			result.resp.Body = &cancelOnClose{ReadCloser: result.resp.Body, cancel: cancels[result.index]}
+			return result.resp, nil
+
+		case <-timer.C:
+			if len(cancels) <= maxExtra && launch() {
+				inFlight++
+				timer.Reset(delay)
+			}
+		}
+	}
+}
+
+// isIdempotent reports whether requests with method can safely be sent twice
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package cumi
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HostConfig holds defaults applied only to requests sent to a specific host.
+// Request-level settings take precedence over host settings, which take
+// precedence over client settings.
+type HostConfig struct {
+	Headers        map[string]string
+	Username       string
+	Password       string
+	BearerToken    string
+	Timeout        time.Duration
+	RetryCount     *int
+	RetryInterval  *time.Duration
+	RetryCondition RetryConditionFunc
+}
+
+// SetHostConfig registers defaults for requests whose resolved URL has the
+// given host. host may include a port ("api.example.com:8443") to match only
+// that port, or be a bare hostname to match any port.
+func (c *Client) SetHostConfig(host string, cfg HostConfig) *Client {
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = v
+	}
+	cfg.Headers = headers
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hostConfigs == nil {
+		c.hostConfigs = make(map[string]HostConfig)
+	}
+	c.hostConfigs[strings.ToLower(host)] = cfg
+	return c
+}
+
+// hostConfig returns the host config matching u, preferring an exact host:port match
+func (c *Client) hostConfig(u *url.URL) *HostConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if cfg, ok := c.hostConfigs[strings.ToLower(u.Host)]; ok {
+		return &cfg
+	}
+	if cfg, ok := c.hostConfigs[strings.ToLower(u.Hostname())]; ok {
+		return &cfg
+	}
+	return nil
+}
+
+// hostConfigFor returns the host config matching the request's resolved URL
+func (c *Client) hostConfigFor(req *Request) *HostConfig {
+	c.mu.RLock()
+	empty := len(c.hostConfigs) == 0
+	c.mu.RUnlock()
+	if empty {
+		return nil
+	}
+
+	u, err := c.buildURL(req)
+	if err != nil {
+		return nil
+	}
+	return c.hostConfig(u)
+}
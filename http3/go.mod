@@ -0,0 +1,27 @@
+module github.com/sofyan48/cumi/http3
+
+go 1.25.0
+
+require (
+	github.com/quic-go/quic-go v0.54.0
+	github.com/sofyan48/cumi v0.1.0
+)
+
+require (
+	github.com/quic-go/qpack v0.5.1 // indirect
+	go.opentelemetry.io/otel v1.38.0 // indirect
+	go.opentelemetry.io/otel/trace v1.38.0 // indirect
+	go.uber.org/mock v0.5.0 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/mod v0.18.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/sync v0.19.0 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	golang.org/x/tools v0.22.0 // indirect
+	google.golang.org/protobuf v1.36.12 // indirect
+)
+
+// Build against the parent module when working inside this repository.
+// Released versions of this module require the matching cumi release above.
+replace github.com/sofyan48/cumi => ../
@@ -0,0 +1,353 @@
+// Package http3 adds experimental HTTP/3 support to cumi clients.
+//
+// It lives in its own module so that users who don't need HTTP/3 are not
+// forced to depend on quic-go.
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sofyan48/cumi"
+)
+
+const (
+	// defaultAltSvcMaxAge is how long an Alt-Svc advertisement without an
+	// "ma" parameter is valid (RFC 7838)
+	defaultAltSvcMaxAge = 24 * time.Hour
+	// brokenBackoff is how long HTTP/3 is skipped for a host after its first
+	// failure; it doubles with every further failure up to maxBrokenBackoff
+	brokenBackoff    = 5 * time.Minute
+	maxBrokenBackoff = time.Hour
+)
+
+// NewClient creates a new cumi client that sends requests over HTTP/3
+func NewClient() *cumi.Client {
+	return UseHTTP3(cumi.NewClient())
+}
+
+// UseHTTP3 lets the client send requests over HTTP/3 to servers that support
+// it. Like browsers, it discovers support from the Alt-Svc header: requests
+// to a host go over the client's existing transport (HTTP/2 or HTTP/1.1)
+// until a response advertises h3 on the same port, and HTTP/3 is used from
+// then on until the advertisement expires. When HTTP/3 fails, HTTP/3 is
+// skipped for that host for a while, so networks that block UDP don't pay the
+// QUIC handshake timeout on every request. The failed request is retried over
+// the existing transport if it can't have reached the server (the QUIC
+// connection could not be established) or is safe to repeat: GET, HEAD,
+// OPTIONS and TRACE, or a request with an Idempotency-Key header. Only those
+// requests may open connections with 0-RTT.
+//
+// Client setters keep working after UseHTTP3: TLS settings apply to both
+// protocols, while proxy, dial, keep-alive and idle connection settings only
+// apply to the existing transport, as QUIC connections can't use them.
+// DisableCompression applies to both. Clones of the client keep HTTP/3.
+func UseHTTP3(c *cumi.Client) *cumi.Client {
+	fallback := c.GetTransport()
+	if _, ok := fallback.(*fallbackTransport); ok {
+		return c
+	}
+	if t, ok := fallback.(*http.Transport); ok {
+		t.ForceAttemptHTTP2 = true
+	}
+	return c.SetTransport(newFallbackTransport(fallback))
+}
+
+// hostState is what the transport remembers about a host
+type hostState struct {
+	h3Until     time.Time // HTTP/3 advertised via Alt-Svc until then
+	brokenUntil time.Time // HTTP/3 failed; skip it until then
+	failures    int
+}
+
+// fallbackTransport sends requests over HTTP/3 to hosts known to support it
+// and over the fallback transport otherwise
+type fallbackTransport struct {
+	primary  http.RoundTripper
+	fallback http.RoundTripper
+	now      func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*hostState
+}
+
+// newFallbackTransport creates a transport that uses HTTP/3 alongside fallback
+func newFallbackTransport(fallback http.RoundTripper) *fallbackTransport {
+	t := &fallbackTransport{
+		fallback: fallback,
+		now:      time.Now,
+		hosts:    make(map[string]*hostState),
+	}
+	t.primary = &http3.Transport{Dial: t.dial}
+	return t
+}
+
+// replayableKey marks the context of requests that are safe to repeat
+type replayableKey struct{}
+
+// dialError is a failure to establish the QUIC connection, so the request
+// was never sent
+type dialError struct{ err error }
+
+func (e *dialError) Error() string { return e.err.Error() }
+func (e *dialError) Unwrap() error { return e.err }
+
+// dial opens a QUIC connection using the fallback transport's current TLS
+// configuration, so TLS setters called after UseHTTP3 apply to HTTP/3 too.
+// 0-RTT is only used for connections opened by replayable requests, as early
+// data can be replayed by an attacker.
+func (t *fallbackTransport) dial(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+	if base := t.HTTPTransport(); base != nil && base.TLSClientConfig != nil {
+		conf := base.TLSClientConfig.Clone()
+		if conf.ServerName == "" {
+			conf.ServerName = tlsCfg.ServerName
+		}
+		conf.NextProtos = tlsCfg.NextProtos
+		tlsCfg = conf
+	}
+	dial := quic.DialAddr
+	if replayable, _ := ctx.Value(replayableKey{}).(bool); replayable {
+		dial = quic.DialAddrEarly
+	}
+	conn, err := dial(ctx, addr, tlsCfg, cfg)
+	if err != nil {
+		return nil, &dialError{err}
+	}
+	return conn, nil
+}
+
+// HTTPTransport implements cumi.HTTPTransportProvider, so client setters
+// configure the fallback transport
+func (t *fallbackTransport) HTTPTransport() *http.Transport {
+	switch fallback := t.fallback.(type) {
+	case *http.Transport:
+		return fallback
+	case cumi.HTTPTransportProvider:
+		return fallback.HTTPTransport()
+	}
+	return nil
+}
+
+// CloneTransport implements cumi.TransportCloner, so clones keep HTTP/3. The
+// clone starts without any remembered hosts.
+func (t *fallbackTransport) CloneTransport() http.RoundTripper {
+	fallback := t.fallback
+	switch f := fallback.(type) {
+	case *http.Transport:
+		fallback = f.Clone()
+	case cumi.TransportCloner:
+		fallback = f.CloneTransport()
+	}
+	return newFallbackTransport(fallback)
+}
+
+// CloseIdleConnections closes idle connections of both transports
+func (t *fallbackTransport) CloseIdleConnections() {
+	type closeIdler interface{ CloseIdleConnections() }
+	for _, rt := range []http.RoundTripper{t.primary, t.fallback} {
+		if c, ok := rt.(closeIdler); ok {
+			c.CloseIdleConnections()
+		}
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *fallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.fallback == nil {
+		return t.primary.RoundTrip(req)
+	}
+	// HTTP/3 only runs over TLS
+	if req.URL.Scheme != "https" || !t.useHTTP3(req.URL) {
+		return t.roundTripFallback(req)
+	}
+
+	replayable := isReplayable(req)
+	h3Req := t.withCompression(req)
+	h3Req = h3Req.WithContext(context.WithValue(h3Req.Context(), replayableKey{}, replayable))
+	resp, err := t.primary.RoundTrip(h3Req)
+	if err == nil {
+		t.recordSuccess(req.URL)
+		t.observeAltSvc(req.URL, resp.Header)
+		return resp, nil
+	}
+	if req.Context().Err() != nil {
+		return nil, err
+	}
+	t.recordFailure(req.URL)
+
+	// The server may have processed the request unless the connection failed
+	var dialErr *dialError
+	if !replayable && !errors.As(err, &dialErr) {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	return t.roundTripFallback(retry)
+}
+
+// roundTripFallback sends req over the fallback transport and records any
+// HTTP/3 advertisement in the response
+func (t *fallbackTransport) roundTripFallback(req *http.Request) (*http.Response, error) {
+	resp, err := t.fallback.RoundTrip(req)
+	if err == nil && req.URL.Scheme == "https" {
+		t.observeAltSvc(req.URL, resp.Header)
+	}
+	return resp, err
+}
+
+// isReplayable reports whether req is safe to send twice, following net/http
+func isReplayable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	// Idempotency keys let the server recognize the repeated request
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	_, ok := req.Header["X-Idempotency-Key"]
+	return ok
+}
+
+// withCompression stops the HTTP/3 transport from requesting gzip when
+// compression is disabled on the fallback transport
+func (t *fallbackTransport) withCompression(req *http.Request) *http.Request {
+	base := t.HTTPTransport()
+	if base == nil || !base.DisableCompression || req.Header.Get("Accept-Encoding") != "" {
+		return req
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "identity")
+	return req
+}
+
+// useHTTP3 reports whether HTTP/3 is advertised for u's host and not broken
+func (t *fallbackTransport) useHTTP3(u *url.URL) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state, ok := t.hosts[hostKey(u)]
+	if !ok {
+		return false
+	}
+	now := t.now()
+	return now.Before(state.h3Until) && !now.Before(state.brokenUntil)
+}
+
+// recordSuccess clears the failure backoff for u's host
+func (t *fallbackTransport) recordSuccess(u *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok := t.hosts[hostKey(u)]; ok {
+		state.failures = 0
+		state.brokenUntil = time.Time{}
+	}
+}
+
+// recordFailure skips HTTP/3 for u's host for an exponentially growing time
+func (t *fallbackTransport) recordFailure(u *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.state(hostKey(u))
+	backoff := brokenBackoff << state.failures
+	if backoff > maxBrokenBackoff || backoff <= 0 {
+		backoff = maxBrokenBackoff
+	}
+	state.failures++
+	state.brokenUntil = t.now().Add(backoff)
+}
+
+// observeAltSvc records whether the Alt-Svc header advertises HTTP/3 on the
+// same port as u
+func (t *fallbackTransport) observeAltSvc(u *url.URL, header http.Header) {
+	values := header.Values("Alt-Svc")
+	if len(values) == 0 {
+		return
+	}
+	maxAge, advertised, cleared := parseAltSvc(strings.Join(values, ","), u.Hostname(), port(u))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case cleared:
+		if state, ok := t.hosts[hostKey(u)]; ok {
+			state.h3Until = time.Time{}
+		}
+	case advertised:
+		t.state(hostKey(u)).h3Until = t.now().Add(maxAge)
+	}
+}
+
+// state returns the state for key, creating it if needed. Callers must hold t.mu.
+func (t *fallbackTransport) state(key string) *hostState {
+	state, ok := t.hosts[key]
+	if !ok {
+		state = &hostState{}
+		t.hosts[key] = state
+	}
+	return state
+}
+
+// parseAltSvc reports whether an Alt-Svc header value advertises h3 for
+// host:port and for how long, or clears all alternatives
+func parseAltSvc(value, host, wantPort string) (maxAge time.Duration, advertised, cleared bool) {
+	if strings.TrimSpace(value) == "clear" {
+		return 0, false, true
+	}
+	for _, entry := range strings.Split(value, ",") {
+		params := strings.Split(entry, ";")
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(params[0]), "=")
+		if !ok || protocol != "h3" {
+			continue
+		}
+		altHost, altPort, err := net.SplitHostPort(strings.Trim(authority, `"`))
+		if err != nil || altHost != "" && altHost != host || altPort != wantPort {
+			continue
+		}
+
+		maxAge = defaultAltSvcMaxAge
+		for _, param := range params[1:] {
+			key, val, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key != "ma" {
+				continue
+			}
+			if seconds, err := strconv.Atoi(strings.Trim(val, `"`)); err == nil {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+		return maxAge, maxAge > 0, false
+	}
+	return 0, false, false
+}
+
+// hostKey identifies the host and port of u
+func hostKey(u *url.URL) string {
+	return net.JoinHostPort(u.Hostname(), port(u))
+}
+
+// port returns u's port, defaulting to 443 for https
+func port(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	return "443"
+}
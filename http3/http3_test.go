@@ -0,0 +1,209 @@
+package http3
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"github.com/sofyan48/cumi"
+)
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestParseAltSvc(t *testing.T) {
+	tests := []struct {
+		value      string
+		maxAge     time.Duration
+		advertised bool
+		cleared    bool
+	}{
+		{`h3=":443"`, defaultAltSvcMaxAge, true, false},
+		{`h3-29=":443", h3=":443"; ma=3600`, time.Hour, true, false},
+		{`h3="example.com:443"; ma=60`, time.Minute, true, false},
+		{`h3="other.com:443"`, 0, false, false},
+		{`h3=":8443"`, 0, false, false},
+		{`h2=":443"`, 0, false, false},
+		{`h3=":443"; ma=0`, 0, false, false},
+		{`clear`, 0, false, true},
+	}
+	for _, tt := range tests {
+		maxAge, advertised, cleared := parseAltSvc(tt.value, "example.com", "443")
+		if maxAge != tt.maxAge || advertised != tt.advertised || cleared != tt.cleared {
+			t.Errorf("parseAltSvc(%q) = %v, %v, %v; want %v, %v, %v", tt.value, maxAge, advertised, cleared, tt.maxAge, tt.advertised, tt.cleared)
+		}
+	}
+}
+
+func TestHostMemory(t *testing.T) {
+	var primaryCalls, fallbackCalls int
+	transport := newFallbackTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		fallbackCalls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Alt-Svc": {`h3=":443"`}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	transport.primary = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		primaryCalls++
+		return nil, errors.New("udp blocked")
+	})
+	now := time.Now()
+	transport.now = func() time.Time { return now }
+
+	send := func() {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+		if _, err := transport.RoundTrip(req); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	expect := func(step string, primary, fallback int) {
+		if primaryCalls != primary || fallbackCalls != fallback {
+			t.Errorf("%s: expected %d HTTP/3 and %d fallback requests, got %d and %d", step, primary, fallback, primaryCalls, fallbackCalls)
+		}
+	}
+
+	send()
+	expect("before Alt-Svc", 0, 1)
+	send()
+	expect("after Alt-Svc", 1, 2)
+	send()
+	expect("while broken", 1, 3)
+
+	now = now.Add(brokenBackoff + time.Second)
+	send()
+	expect("after the backoff", 2, 4)
+
+	now = now.Add(brokenBackoff + time.Second)
+	send()
+	expect("during the doubled backoff", 2, 5)
+
+	// Plain HTTP never uses HTTP/3
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+	transport.RoundTrip(req)
+	expect("plain HTTP", 2, 6)
+}
+
+func TestFallbackOnlyWhenSafe(t *testing.T) {
+	var fallbackCalls int
+	transport := newFallbackTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		fallbackCalls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Alt-Svc": {`h3=":443"`}},
+			Body:       http.NoBody,
+		}, nil
+	}))
+	var primaryErr error
+	var early bool
+	transport.primary = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		early, _ = req.Context().Value(replayableKey{}).(bool)
+		return nil, primaryErr
+	})
+	now := time.Now()
+	transport.now = func() time.Time { return now }
+
+	// Learn that the host supports HTTP/3
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/", nil)
+	transport.RoundTrip(req)
+
+	tests := []struct {
+		method     string
+		header     http.Header
+		err        error
+		replayable bool
+		fallsBack  bool
+	}{
+		{http.MethodGet, nil, errors.New("stream reset"), true, true},
+		{http.MethodPost, nil, errors.New("stream reset"), false, false},
+		{http.MethodPost, nil, &dialError{errors.New("handshake timeout")}, false, true},
+		{http.MethodPost, http.Header{"Idempotency-Key": {"1"}}, errors.New("stream reset"), true, true},
+	}
+	for _, tt := range tests {
+		// Skip past the backoff from the previous failure
+		now = now.Add(maxBrokenBackoff + time.Second)
+		primaryErr = tt.err
+		fallbackCalls = 0
+		req, _ := http.NewRequest(tt.method, "https://example.com/", strings.NewReader("body"))
+		for k, v := range tt.header {
+			req.Header[k] = v
+		}
+		_, err := transport.RoundTrip(req)
+		if early != tt.replayable {
+			t.Errorf("%s %v: expected replayable=%v for dialing, got %v", tt.method, tt.err, tt.replayable, early)
+		}
+		if fellBack := fallbackCalls == 1; fellBack != tt.fallsBack || (err == nil) != tt.fallsBack {
+			t.Errorf("%s %v: expected fallback=%v, got %v (err=%v)", tt.method, tt.err, tt.fallsBack, fellBack, err)
+		}
+	}
+}
+
+func TestUseHTTP3(t *testing.T) {
+	tcpServer := httptest.NewUnstartedServer(nil)
+	tcpServer.StartTLS()
+	defer tcpServer.Close()
+	_, port, _ := net.SplitHostPort(tcpServer.Listener.Addr().String())
+	tcpServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Alt-Svc", `h3=":`+port+`"; ma=60`)
+		io.WriteString(w, r.Proto)
+	})
+
+	udpPort, _ := strconv.Atoi(port)
+	udpConn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: udpPort})
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	h3Server := &http3.Server{
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: tcpServer.TLS.Certificates}),
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Proto)
+		}),
+	}
+	go h3Server.Serve(udpConn)
+	defer h3Server.Close()
+
+	// Setters called after UseHTTP3 still configure TLS for both protocols
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tcpServer.Certificate().Raw})
+	client := UseHTTP3(cumi.NewClient()).SetRootCAs(certPEM).SetTimeout(5 * time.Second)
+
+	protos := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(tcpServer.URL).Execute()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		protos = append(protos, resp.String())
+	}
+	if !strings.HasPrefix(protos[0], "HTTP/1") && !strings.HasPrefix(protos[0], "HTTP/2") || protos[1] != "HTTP/3.0" {
+		t.Errorf("Expected TCP and then HTTP/3 after Alt-Svc, got %v", protos)
+	}
+
+	client.DisableCompression()
+	transport := client.GetTransport().(*fallbackTransport)
+	if !transport.HTTPTransport().DisableCompression {
+		t.Errorf("Expected DisableCompression to configure the fallback transport")
+	}
+
+	clone := client.Clone()
+	cloned, ok := clone.GetTransport().(*fallbackTransport)
+	if !ok {
+		t.Fatalf("Expected the clone to keep HTTP/3, got %T", clone.GetTransport())
+	}
+	if cloned.HTTPTransport() == transport.HTTPTransport() || !cloned.HTTPTransport().DisableCompression {
+		t.Errorf("Expected the clone to get its own copy of the fallback transport")
+	}
+}
@@ -0,0 +1,126 @@
+package cumi
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// RequestMetrics describes a completed logical request, including retries
+type RequestMetrics struct {
+	Method          string
+	Host            string
+	PathTemplate    string
+	StatusCode      int
+	Attempts        int
+	BytesIn         int64
+	BytesOut        int64
+	DNSLookup       time.Duration
+	ConnTime        time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	Duration        time.Duration
+	Err             error
+}
+
+// TraceInfo holds connection timings for a single request attempt
+type TraceInfo struct {
+	DNSLookup       time.Duration
+	ConnTime        time.Duration
+	TLSHandshake    time.Duration
+	TimeToFirstByte time.Duration
+	IsConnReused    bool
+	IsConnWasIdle   bool
+	ConnIdleTime    time.Duration
+}
+
+// MetricsHook receives metrics once per logical request
+type MetricsHook func(RequestMetrics)
+
+// connTimer records connection timings for a single attempt via httptrace
+type connTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+	wasIdle      bool
+	idleTime     time.Duration
+}
+
+// withConnTimer attaches a connTimer to the request context
+func withConnTimer(httpReq *http.Request) (*http.Request, *connTimer) {
+	t := &connTimer{start: time.Now()}
+	ctx := httptrace.WithClientTrace(httpReq.Context(), t.clientTrace())
+	return httpReq.WithContext(ctx), t
+}
+
+// clientTrace returns the httptrace hooks that record into t
+func (t *connTimer) clientTrace() *httptrace.ClientTrace {
+	record := func(field *time.Time) {
+		t.mu.Lock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+		t.mu.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart: func(string, string) { record(&t.connectStart) },
+		ConnectDone: func(string, string, error) {
+			t.mu.Lock()
+			t.connectDone = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart:    func() { record(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.reused = info.Reused
+			t.wasIdle = info.WasIdle
+			t.idleTime = info.IdleTime
+			t.mu.Unlock()
+		},
+	}
+}
+
+// span returns the duration between two recorded points, or zero if either is missing
+func span(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// traceInfo returns the recorded timings
+func (t *connTimer) traceInfo() TraceInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return TraceInfo{
+		DNSLookup:       span(t.dnsStart, t.dnsDone),
+		ConnTime:        span(t.connectStart, t.connectDone),
+		TLSHandshake:    span(t.tlsStart, t.tlsDone),
+		TimeToFirstByte: span(t.start, t.firstByte),
+		IsConnReused:    t.reused,
+		IsConnWasIdle:   t.wasIdle,
+		ConnIdleTime:    t.idleTime,
+	}
+}
+
+// fill copies the recorded timings into m
+func (t *connTimer) fill(m *RequestMetrics) {
+	info := t.traceInfo()
+	m.DNSLookup = info.DNSLookup
+	m.ConnTime = info.ConnTime
+	m.TLSHandshake = info.TLSHandshake
+	m.TimeToFirstByte = info.TimeToFirstByte
+}
@@ -0,0 +1,104 @@
+package cumi
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// cookieJar is the cookie jar clients create. It remembers the cookies it was
+// given, since cookiejar.Jar can't list them, so it can be copied with every
+// cookie attribute (Domain, Path, Secure, Expires) intact.
+type cookieJar struct {
+	options *cookiejar.Options
+
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	records []cookieRecord
+	index   map[cookieRecordKey]int
+}
+
+// cookieRecord is a cookie as received from u
+type cookieRecord struct {
+	u      *url.URL
+	cookie *http.Cookie
+}
+
+// cookieRecordKey identifies a cookie, so newer ones replace its record
+type cookieRecordKey struct {
+	host, domain, path, name string
+}
+
+// NewCookieJar creates an in-memory cookie jar like cookiejar.New, e.g. with
+// a public suffix list. Unlike other custom jars, it can be copied by
+// CloneWithCookies and emptied by ClearCookies, which keep its options.
+func NewCookieJar(options *cookiejar.Options) http.CookieJar {
+	return newCookieJar(options)
+}
+
+// newCookieJar creates an empty jar with options
+func newCookieJar(options *cookiejar.Options) *cookieJar {
+	jar, _ := cookiejar.New(options)
+	return &cookieJar{options: options, jar: jar, index: make(map[cookieRecordKey]int)}
+}
+
+// Cookies implements http.CookieJar
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.jar.Cookies(u)
+}
+
+// SetCookies implements http.CookieJar
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+
+	now := time.Now()
+	for _, cookie := range cookies {
+		// Pin relative lifetimes so a copy expires the cookie at the same time
+		cookie := *cookie
+		if cookie.MaxAge > 0 {
+			cookie.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			cookie.MaxAge = 0
+		}
+		key := cookieRecordKey{u.Host, cookie.Domain, cookie.Path, cookie.Name}
+		if i, ok := j.index[key]; ok {
+			// Keep records in order, as later cookies may replace earlier ones
+			j.records[i].cookie = nil
+		}
+		j.index[key] = len(j.records)
+		j.records = append(j.records, cookieRecord{u: u, cookie: &cookie})
+	}
+	if len(j.records) > 2*len(j.index)+16 {
+		j.compact()
+	}
+}
+
+// compact drops replaced records. Callers must hold j.mu.
+func (j *cookieJar) compact() {
+	records := j.records[:0]
+	for _, record := range j.records {
+		if record.cookie != nil {
+			key := cookieRecordKey{record.u.Host, record.cookie.Domain, record.cookie.Path, record.cookie.Name}
+			j.index[key] = len(records)
+			records = append(records, record)
+		}
+	}
+	clear(j.records[len(records):])
+	j.records = records
+}
+
+// clone returns a new jar with the same options and cookies
+func (j *cookieJar) clone() *cookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	clone := newCookieJar(j.options)
+	for _, record := range j.records {
+		if record.cookie != nil {
+			clone.SetCookies(record.u, []*http.Cookie{record.cookie})
+		}
+	}
+	return clone
+}
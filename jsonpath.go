@@ -0,0 +1,180 @@
+package cumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath extracts a value from a JSON response body using a dotted path
+// with optional array indexes, e.g. "data.items[0].id"
+func (r *Response) JSONPath(path string) (interface{}, error) {
+	if !r.IsJSON() && !strings.Contains(r.ContentType(), "+json") {
+		return nil, fmt.Errorf("response content type %q is not JSON", r.ContentType())
+	}
+
+	var root interface{}
+	decoder := json.NewDecoder(bytes.NewReader(r.body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON body: %w", err)
+	}
+
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current := root
+	walked := ""
+	for _, token := range tokens {
+		if token.isIndex {
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("path %q: %q is not an array", path, walked)
+			}
+			if token.index < 0 || token.index >= len(arr) {
+				return nil, fmt.Errorf("path %q: index %d out of range at %q", path, token.index, walked)
+			}
+			current = arr[token.index]
+			walked += "[" + strconv.Itoa(token.index) + "]"
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("path %q: %q is not an object", path, walked)
+		}
+		if walked != "" {
+			walked += "."
+		}
+		walked += token.key
+		value, ok := obj[token.key]
+		if !ok {
+			return nil, fmt.Errorf("path %q: %q not found", path, walked)
+		}
+		current = value
+	}
+
+	return current, nil
+}
+
+// JSONPathString extracts a string value at the given JSON path
+func (r *Response) JSONPathString(path string) (string, error) {
+	value, err := r.JSONPath(path)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("path %q: expected string, got %s", path, jsonTypeName(value))
+	}
+	return s, nil
+}
+
+// JSONPathInt extracts an integer value at the given JSON path
+func (r *Response) JSONPathInt(path string) (int64, error) {
+	value, err := r.JSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("path %q: expected number, got %s", path, jsonTypeName(value))
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("path %q: %s is not an integer", path, n)
+	}
+	return i, nil
+}
+
+// JSONPathFloat extracts a floating point value at the given JSON path
+func (r *Response) JSONPathFloat(path string) (float64, error) {
+	value, err := r.JSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("path %q: expected number, got %s", path, jsonTypeName(value))
+	}
+	return n.Float64()
+}
+
+// JSONPathBool extracts a boolean value at the given JSON path
+func (r *Response) JSONPathBool(path string) (bool, error) {
+	value, err := r.JSONPath(path)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("path %q: expected bool, got %s", path, jsonTypeName(value))
+	}
+	return b, nil
+}
+
+// jsonPathToken is a single object key or array index in a JSON path
+type jsonPathToken struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseJSONPath splits a path like "data.items[0].id" into tokens
+func parseJSONPath(path string) ([]jsonPathToken, error) {
+	var tokens []jsonPathToken
+	if path == "" {
+		return tokens, nil
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		rest := ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			key, rest = part[:i], part[i:]
+		}
+		if key == "" && rest == "" {
+			return nil, fmt.Errorf("invalid JSON path %q: empty segment", path)
+		}
+		if key != "" {
+			tokens = append(tokens, jsonPathToken{key: key})
+		}
+
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: malformed index in %q", path, part)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index in %q", path, part)
+			}
+			tokens = append(tokens, jsonPathToken{index: index, isIndex: true})
+			rest = rest[end+1:]
+		}
+	}
+	return tokens, nil
+}
+
+// jsonTypeName describes a decoded JSON value for error messages
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,756 @@
+package cumi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MsgPackExt is a MessagePack extension value with an application-defined
+// type. Extensions other than timestamps decode to MsgPackExt.
+type MsgPackExt struct {
+	Type int8
+	Data []byte
+}
+
+// msgPackTimestampExt is the extension type reserved for timestamps
+const msgPackTimestampExt = -1
+
+// msgPackMaxDepth limits how deeply arrays and maps may nest when decoding,
+// so hostile input can't exhaust the stack
+const msgPackMaxDepth = 10000
+
+// msgPackMaxPrealloc caps the capacity reserved from a declared array or map
+// length, so nested headers claiming large lengths can't multiply memory use
+const msgPackMaxPrealloc = 1024
+
+var msgPackExtType = reflect.TypeOf(MsgPackExt{})
+
+// MsgPackMarshal encodes v as MessagePack.
+// Structs are encoded as maps keyed by the `msgpack` tag, falling back to the
+// `json` tag or the field name; time.Time uses the timestamp extension.
+func MsgPackMarshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgPackEncode(&buf, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MsgPackUnmarshal decodes MessagePack data into v, which must be a non-nil pointer
+func MsgPackUnmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("msgpack: unmarshal target must be a non-nil pointer")
+	}
+	value, err := msgPackDecode(bytes.NewReader(data), 0)
+	if err != nil {
+		return err
+	}
+	return msgPackAssign(rv.Elem(), value)
+}
+
+// msgPackEncode writes a single value
+func msgPackEncode(buf *bytes.Buffer, rv reflect.Value) error {
+	if !rv.IsValid() {
+		buf.WriteByte(0xc0)
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		return msgPackEncode(buf, rv.Elem())
+	}
+	if rv.Type() == timeType {
+		msgPackWriteTime(buf, rv.Interface().(time.Time))
+		return nil
+	}
+	if rv.Type() == msgPackExtType {
+		ext := rv.Interface().(MsgPackExt)
+		msgPackWriteExt(buf, ext.Type, ext.Data)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		msgPackWriteInt(buf, rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		msgPackWriteUint(buf, rv.Uint())
+	case reflect.Float32:
+		buf.WriteByte(0xca)
+		binary.Write(buf, binary.BigEndian, math.Float32bits(float32(rv.Float())))
+	case reflect.Float64:
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(rv.Float()))
+	case reflect.String:
+		msgPackWriteString(buf, rv.String())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(data), rv)
+			msgPackWriteBin(buf, data)
+			return nil
+		}
+		msgPackWriteLen(buf, rv.Len(), 0x90, 0xdc, 0xdd)
+		for i := 0; i < rv.Len(); i++ {
+			if err := msgPackEncode(buf, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if rv.IsNil() {
+			buf.WriteByte(0xc0)
+			return nil
+		}
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		msgPackWriteLen(buf, len(keys), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			if err := msgPackEncode(buf, k); err != nil {
+				return err
+			}
+			if err := msgPackEncode(buf, rv.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return msgPackEncodeStruct(buf, rv)
+	default:
+		return fmt.Errorf("msgpack: unsupported type %s", rv.Type())
+	}
+	return nil
+}
+
+// msgPackEncodeStruct writes a struct as a map of its exported fields
+func msgPackEncodeStruct(buf *bytes.Buffer, rv reflect.Value) error {
+	type field struct {
+		name  string
+		value reflect.Value
+	}
+	var fields []field
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := msgPackFieldName(sf)
+		if skip || (omitEmpty && rv.Field(i).IsZero()) {
+			continue
+		}
+		fields = append(fields, field{name: name, value: rv.Field(i)})
+	}
+
+	msgPackWriteLen(buf, len(fields), 0x80, 0xde, 0xdf)
+	for _, f := range fields {
+		msgPackWriteString(buf, f.name)
+		if err := msgPackEncode(buf, f.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// msgPackFieldName resolves the map key for a struct field from its tags
+func msgPackFieldName(sf reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag, ok := sf.Tag.Lookup("msgpack")
+	if !ok {
+		tag, ok = sf.Tag.Lookup("json")
+	}
+	if !ok {
+		return sf.Name, false, false
+	}
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = sf.Name
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+func msgPackWriteInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0:
+		msgPackWriteUint(buf, uint64(n))
+	case n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(n))
+	case n >= math.MinInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(n))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func msgPackWriteUint(buf *bytes.Buffer, n uint64) {
+	switch {
+	case n <= 0x7f:
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xcc)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xcd)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(0xce)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(0xcf)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func msgPackWriteString(buf *bytes.Buffer, s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		buf.WriteByte(0xa0 | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xda)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xdb)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteString(s)
+}
+
+func msgPackWriteBin(buf *bytes.Buffer, data []byte) {
+	n := len(data)
+	switch {
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc4)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc5)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc6)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.Write(data)
+}
+
+// msgPackWriteTime writes t with the timestamp extension, using the 32, 64 or
+// 96-bit form depending on the range and precision needed
+func msgPackWriteTime(buf *bytes.Buffer, t time.Time) {
+	sec, nsec := t.Unix(), int64(t.Nanosecond())
+	switch {
+	case nsec == 0 && sec >= 0 && sec <= math.MaxUint32:
+		data := make([]byte, 4)
+		binary.BigEndian.PutUint32(data, uint32(sec))
+		msgPackWriteExt(buf, msgPackTimestampExt, data)
+	case sec >= 0 && sec < 1<<34:
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(nsec)<<34|uint64(sec))
+		msgPackWriteExt(buf, msgPackTimestampExt, data)
+	default:
+		data := make([]byte, 12)
+		binary.BigEndian.PutUint32(data, uint32(nsec))
+		binary.BigEndian.PutUint64(data[4:], uint64(sec))
+		msgPackWriteExt(buf, msgPackTimestampExt, data)
+	}
+}
+
+// msgPackWriteExt writes an extension value, using the fixext form when the
+// data length allows it
+func msgPackWriteExt(buf *bytes.Buffer, extType int8, data []byte) {
+	n := len(data)
+	switch {
+	case n == 1:
+		buf.WriteByte(0xd4)
+	case n == 2:
+		buf.WriteByte(0xd5)
+	case n == 4:
+		buf.WriteByte(0xd6)
+	case n == 8:
+		buf.WriteByte(0xd7)
+	case n == 16:
+		buf.WriteByte(0xd8)
+	case n <= math.MaxUint8:
+		buf.WriteByte(0xc7)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(0xc8)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(0xc9)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+	buf.WriteByte(byte(extType))
+	buf.Write(data)
+}
+
+// msgPackWriteLen writes an array or map header using the fix, 16-bit or 32-bit form
+func msgPackWriteLen(buf *bytes.Buffer, n int, fix, code16, code32 byte) {
+	switch {
+	case n <= 15:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(code16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(code32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// msgPackDecode reads a single value into its generic Go representation:
+// nil, bool, int64, uint64, float64, string, []byte, []interface{},
+// map[interface{}]interface{}, time.Time or MsgPackExt. depth is the number
+// of enclosing arrays and maps.
+func msgPackDecode(r *bytes.Reader, depth int) (interface{}, error) {
+	code, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xf0 == 0x80:
+		return msgPackDecodeMap(r, int(code&0x0f), depth)
+	case code&0xf0 == 0x90:
+		return msgPackDecodeArray(r, int(code&0x0f), depth)
+	case code&0xe0 == 0xa0:
+		return msgPackReadString(r, int(code&0x1f))
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := msgPackReadLen(r, code-0xc4)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackReadBytes(r, n)
+	case 0xc7, 0xc8, 0xc9:
+		n, err := msgPackReadLen(r, code-0xc7)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackReadExt(r, n)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return msgPackReadExt(r, 1<<(code-0xd4))
+	case 0xca:
+		var bits uint32
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(bits)), nil
+	case 0xcb:
+		var bits uint64
+		if err := binary.Read(r, binary.BigEndian, &bits); err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(bits), nil
+	case 0xcc:
+		var n uint8
+		err := binary.Read(r, binary.BigEndian, &n)
+		return uint64(n), err
+	case 0xcd:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return uint64(n), err
+	case 0xce:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return uint64(n), err
+	case 0xcf:
+		var n uint64
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	case 0xd0:
+		var n int8
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xd1:
+		var n int16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xd2:
+		var n int32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int64(n), err
+	case 0xd3:
+		var n int64
+		err := binary.Read(r, binary.BigEndian, &n)
+		return n, err
+	case 0xd9, 0xda, 0xdb:
+		n, err := msgPackReadLen(r, code-0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackReadString(r, n)
+	case 0xdc, 0xdd:
+		n, err := msgPackReadLen(r, code-0xdc+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackDecodeArray(r, n, depth)
+	case 0xde, 0xdf:
+		n, err := msgPackReadLen(r, code-0xde+1)
+		if err != nil {
+			return nil, err
+		}
+		return msgPackDecodeMap(r, n, depth)
+	}
+	return nil, fmt.Errorf("msgpack: unsupported format 0x%02x", code)
+}
+
+// msgPackReadLen reads a length of 1, 2 or 4 bytes for size 0, 1 or 2
+func msgPackReadLen(r *bytes.Reader, size byte) (int, error) {
+	switch size {
+	case 0:
+		var n uint8
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	case 1:
+		var n uint16
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	default:
+		var n uint32
+		err := binary.Read(r, binary.BigEndian, &n)
+		return int(n), err
+	}
+}
+
+func msgPackReadBytes(r *bytes.Reader, n int) ([]byte, error) {
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	data := make([]byte, n)
+	_, err := io.ReadFull(r, data)
+	return data, err
+}
+
+func msgPackReadString(r *bytes.Reader, n int) (string, error) {
+	data, err := msgPackReadBytes(r, n)
+	return string(data), err
+}
+
+// msgPackReadExt reads an extension value with n bytes of data, decoding
+// timestamps to time.Time
+func msgPackReadExt(r *bytes.Reader, n int) (interface{}, error) {
+	extType, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	data, err := msgPackReadBytes(r, n)
+	if err != nil {
+		return nil, err
+	}
+	if int8(extType) != msgPackTimestampExt {
+		return MsgPackExt{Type: int8(extType), Data: data}, nil
+	}
+
+	switch n {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data)
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)), nil
+	}
+	return nil, fmt.Errorf("msgpack: invalid timestamp length %d", n)
+}
+
+func msgPackDecodeArray(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth >= msgPackMaxDepth {
+		return nil, fmt.Errorf("msgpack: exceeded max depth of %d", msgPackMaxDepth)
+	}
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	arr := make([]interface{}, 0, min(n, msgPackMaxPrealloc))
+	for i := 0; i < n; i++ {
+		v, err := msgPackDecode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, v)
+	}
+	return arr, nil
+}
+
+func msgPackDecodeMap(r *bytes.Reader, n, depth int) (interface{}, error) {
+	if depth >= msgPackMaxDepth {
+		return nil, fmt.Errorf("msgpack: exceeded max depth of %d", msgPackMaxDepth)
+	}
+	if n > r.Len() {
+		return nil, fmt.Errorf("msgpack: %w", io.ErrUnexpectedEOF)
+	}
+	m := make(map[interface{}]interface{}, min(n, msgPackMaxPrealloc))
+	for i := 0; i < n; i++ {
+		k, err := msgPackDecode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := msgPackDecode(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := k.([]byte); ok {
+			k = string(b)
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return nil, fmt.Errorf("msgpack: unsupported map key type %T", k)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// msgPackAssign stores a decoded generic value into dst
+func msgPackAssign(dst reflect.Value, src interface{}) error {
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return msgPackAssign(dst.Elem(), src)
+	case reflect.Interface:
+		if dst.NumMethod() == 0 {
+			dst.Set(reflect.ValueOf(msgPackGeneric(src)))
+			return nil
+		}
+	}
+
+	if dst.Type() == timeType {
+		// Accept RFC3339 strings as well as the timestamp extension
+		switch v := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(v))
+			return nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				return fmt.Errorf("msgpack: %w", err)
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		return fmt.Errorf("msgpack: cannot decode %T into time.Time", src)
+	}
+	if ext, ok := src.(MsgPackExt); ok && dst.Type() == msgPackExtType {
+		dst.Set(reflect.ValueOf(ext))
+		return nil
+	}
+
+	switch v := src.(type) {
+	case bool:
+		if dst.Kind() == reflect.Bool {
+			dst.SetBool(v)
+			return nil
+		}
+	case int64:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if dst.OverflowInt(v) {
+				return fmt.Errorf("msgpack: %d overflows %s", v, dst.Type())
+			}
+			dst.SetInt(v)
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if v >= 0 && !dst.OverflowUint(uint64(v)) {
+				dst.SetUint(uint64(v))
+				return nil
+			}
+			return fmt.Errorf("msgpack: %d overflows %s", v, dst.Type())
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case uint64:
+		switch dst.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v > math.MaxInt64 || dst.OverflowInt(int64(v)) {
+				return fmt.Errorf("msgpack: %d overflows %s", v, dst.Type())
+			}
+			dst.SetInt(int64(v))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if dst.OverflowUint(v) {
+				return fmt.Errorf("msgpack: %d overflows %s", v, dst.Type())
+			}
+			dst.SetUint(v)
+			return nil
+		case reflect.Float32, reflect.Float64:
+			dst.SetFloat(float64(v))
+			return nil
+		}
+	case float64:
+		if dst.Kind() == reflect.Float32 || dst.Kind() == reflect.Float64 {
+			dst.SetFloat(v)
+			return nil
+		}
+	case string:
+		if dst.Kind() == reflect.String {
+			dst.SetString(v)
+			return nil
+		}
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes([]byte(v))
+			return nil
+		}
+	case []byte:
+		if dst.Kind() == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte(nil), v...))
+			return nil
+		}
+		if dst.Kind() == reflect.String {
+			dst.SetString(string(v))
+			return nil
+		}
+	case []interface{}:
+		switch dst.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(dst.Type(), len(v), len(v))
+			for i, item := range v {
+				if err := msgPackAssign(slice.Index(i), item); err != nil {
+					return err
+				}
+			}
+			dst.Set(slice)
+			return nil
+		case reflect.Array:
+			for i := 0; i < dst.Len() && i < len(v); i++ {
+				if err := msgPackAssign(dst.Index(i), v[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case map[interface{}]interface{}:
+		switch dst.Kind() {
+		case reflect.Map:
+			if dst.IsNil() {
+				dst.Set(reflect.MakeMapWithSize(dst.Type(), len(v)))
+			}
+			for k, item := range v {
+				key := reflect.New(dst.Type().Key()).Elem()
+				if err := msgPackAssign(key, k); err != nil {
+					return err
+				}
+				elem := reflect.New(dst.Type().Elem()).Elem()
+				if err := msgPackAssign(elem, item); err != nil {
+					return err
+				}
+				dst.SetMapIndex(key, elem)
+			}
+			return nil
+		case reflect.Struct:
+			return msgPackAssignStruct(dst, v)
+		}
+	}
+	return fmt.Errorf("msgpack: cannot decode %T into %s", src, dst.Type())
+}
+
+// msgPackAssignStruct fills struct fields from a decoded map, matching keys
+// exactly first and then case-insensitively
+func msgPackAssignStruct(dst reflect.Value, m map[interface{}]interface{}) error {
+	rt := dst.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name, _, skip := msgPackFieldName(sf)
+		if skip {
+			continue
+		}
+		value, ok := m[name]
+		if !ok {
+			for k, v := range m {
+				if s, isString := k.(string); isString && strings.EqualFold(s, name) {
+					value, ok = v, true
+					break
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if err := msgPackAssign(dst.Field(i), value); err != nil {
+			return fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+	}
+	return nil
+}
+
+// msgPackGeneric converts maps with string keys to map[string]interface{} for interface targets
+func msgPackGeneric(src interface{}) interface{} {
+	switch v := src.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = msgPackGeneric(v[i])
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			s, ok := k.(string)
+			if !ok {
+				return v
+			}
+			m[s] = msgPackGeneric(item)
+		}
+		return m
+	}
+	return src
+}
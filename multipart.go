@@ -0,0 +1,68 @@
+package cumi
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"strings"
+	"sync"
+)
+
+// SetBodyMultipart sets a multipart/form-data body built by build. The parts
+// are streamed to the server through a pipe as build writes them, so large
+// files are never held in memory. build runs once per attempt and must not
+// call w.Close.
+func (r *Request) SetBodyMultipart(build func(w *multipart.Writer) error) *Request {
+	r.body = build
+	r.bodyType = "multipart"
+	return r
+}
+
+// multipartReader streams a multipart body, starting the writer goroutine on
+// the first Read so unsent requests do not leak it
+type multipartReader struct {
+	pr    *io.PipeReader
+	pw    *io.PipeWriter
+	mw    *multipart.Writer
+	build func(w *multipart.Writer) error
+	once  sync.Once
+}
+
+// newMultipartReader returns the streaming body and its Content-Type
+func newMultipartReader(build func(w *multipart.Writer) error) (*multipartReader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	return &multipartReader{pr: pr, pw: pw, mw: mw, build: build}, mw.FormDataContentType()
+}
+
+// Read starts writing the parts on first use and reads the encoded body
+func (m *multipartReader) Read(b []byte) (int, error) {
+	m.once.Do(func() {
+		go func() {
+			err := m.build(m.mw)
+			if err == nil {
+				err = m.mw.Close()
+			}
+			m.pw.CloseWithError(err)
+		}()
+	})
+	return m.pr.Read(b)
+}
+
+// Close stops the writer if it is still running
+func (m *multipartReader) Close() error {
+	return m.pr.Close()
+}
+
+// multipartContentType returns contentType with the boundary of the generated
+// multipart Content-Type, as in "multipart/related; boundary=...". Types that
+// aren't multipart would hide the boundary, so generated is returned instead.
+func multipartContentType(contentType, generated string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	_, generatedParams, generatedErr := mime.ParseMediaType(generated)
+	if err != nil || generatedErr != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return generated
+	}
+	params["boundary"] = generatedParams["boundary"]
+	return mime.FormatMediaType(mediaType, params)
+}
@@ -0,0 +1,49 @@
+package cumi
+
+import "encoding/json"
+
+// Content types for JSON based request bodies, keyed by body type
+var jsonBodyContentTypes = map[string]string{
+	"json":        "application/json",
+	"json-patch":  "application/json-patch+json",
+	"merge-patch": "application/merge-patch+json",
+}
+
+// PatchOp is a single RFC 6902 JSON Patch operation
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON always includes the value for operations that require one, so
+// a nil Value is sent as null instead of being omitted
+func (p PatchOp) MarshalJSON() ([]byte, error) {
+	switch p.Op {
+	case "add", "replace", "test":
+		return json.Marshal(struct {
+			Op    string      `json:"op"`
+			Path  string      `json:"path"`
+			Value interface{} `json:"value"`
+		}{p.Op, p.Path, p.Value})
+	}
+	type plain PatchOp
+	return json.Marshal(plain(p))
+}
+
+// SetBodyJSONPatch sets an RFC 6902 JSON Patch body with the
+// application/json-patch+json content type
+func (r *Request) SetBodyJSONPatch(ops []PatchOp) *Request {
+	r.body = ops
+	r.bodyType = "json-patch"
+	return r
+}
+
+// SetBodyMergePatch sets an RFC 7396 JSON Merge Patch body with the
+// application/merge-patch+json content type
+func (r *Request) SetBodyMergePatch(v interface{}) *Request {
+	r.body = v
+	r.bodyType = "merge-patch"
+	return r
+}
@@ -0,0 +1,25 @@
+package cumi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// protoMarshal marshals a protobuf message body
+func protoMarshal(v interface{}) ([]byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("body of type %T is not a proto.Message", v)
+	}
+	return proto.Marshal(msg)
+}
+
+// protoUnmarshal unmarshals protobuf data into a destination message
+func protoUnmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("result of type %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, msg)
+}
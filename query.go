@@ -0,0 +1,29 @@
+package cumi
+
+import (
+	"net/url"
+	"reflect"
+)
+
+// queryEncoder encodes structs as query params. Field names come from the
+// `url` tag, falling back to the `json` tag and then the field name, and
+// slices of scalars are encoded as repeated keys.
+var queryEncoder = &structEncoder{source: "query params", fieldOpts: queryFieldOptions}
+
+// encodeQueryStruct reflects over a struct and adds its fields to values.
+// See structEncoder for how values are encoded.
+func encodeQueryStruct(values url.Values, v interface{}) error {
+	return queryEncoder.encode(values, v)
+}
+
+// queryFieldOptions resolves the query key and options for a struct field from its tags
+func queryFieldOptions(field reflect.StructField) (opts fieldOptions, skip bool) {
+	tag, ok := field.Tag.Lookup("url")
+	if !ok {
+		tag, ok = field.Tag.Lookup("json")
+	}
+	if ok && tag == "-" {
+		return opts, true
+	}
+	return parseFieldTag(field, tag), false
+}
@@ -6,8 +6,11 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
 )
 
 // Request represents an HTTP request
@@ -22,6 +25,7 @@ type Request struct {
 	formData    url.Values
 	body        interface{}
 	bodyType    string
+	contentType string
 	cookies     []*http.Cookie
 	userAgent   string
 	basicAuth   struct {
@@ -29,12 +33,33 @@ type Request struct {
 		password string
 	}
 	bearerToken    string
+	fetchedToken   string
+	requestID      string
 	successResult  interface{}
 	errorResult    interface{}
 	downloadPath   string
+	resumeOutput   bool
+	writer         io.Writer
 	uploadCallback func(written int64, total int64)
 	tracer         trace.Tracer
 	spanName       string
+	retryCount     *int
+	retryInterval  *time.Duration
+	retryCondition RetryConditionFullFunc
+	awsSigV4       *awsSigV4
+	timeout        time.Duration
+	deadline       time.Time
+	transport      http.RoundTripper
+	stream         bool
+	noCookieJar    bool
+	noAuth         bool // prepare without auth, for Fingerprint
+	baseURL        string
+	strictJSON     *bool
+	jsonMarshal    func(v interface{}) ([]byte, error)
+	jsonUnmarshal  func(data []byte, v interface{}) error
+	xmlMarshal     func(v interface{}) ([]byte, error)
+	xmlUnmarshal   func(data []byte, v interface{}) error
+	err            error
 }
 
 // SetContext sets the context for the request
@@ -43,6 +68,26 @@ func (r *Request) SetContext(ctx context.Context) *Request {
 	return r
 }
 
+// WithTimeout sets a timeout for the request, derived from the request context
+// when the request is executed
+func (r *Request) WithTimeout(timeout time.Duration) *Request {
+	r.timeout = timeout
+	return r
+}
+
+// WithDeadline sets a deadline for the request, derived from the request context
+// when the request is executed
+func (r *Request) WithDeadline(deadline time.Time) *Request {
+	r.deadline = deadline
+	return r
+}
+
+// WithValue attaches a value to the request context, keeping the existing context
+func (r *Request) WithValue(key, value interface{}) *Request {
+	r.ctx = context.WithValue(r.Context(), key, value)
+	return r
+}
+
 // Context returns the request context
 func (r *Request) Context() context.Context {
 	if r.ctx == nil {
@@ -51,6 +96,19 @@ func (r *Request) Context() context.Context {
 	return r.ctx
 }
 
+// SetBaseURL overrides the client base URL for this request only
+func (r *Request) SetBaseURL(baseURL string) *Request {
+	normalized, err := normalizeBaseURL(baseURL)
+	if err != nil {
+		if r.err == nil {
+			r.err = err
+		}
+		return r
+	}
+	r.baseURL = normalized
+	return r
+}
+
 // SetHeader sets a header for the request
 func (r *Request) SetHeader(key, value string) *Request {
 	r.headers.Set(key, value)
@@ -77,6 +135,40 @@ func (r *Request) SetHeaderVerbatim(key, value string) *Request {
 	return r
 }
 
+// SetIdempotencyKey sets the Idempotency-Key header, generating a random UUID
+// when key is empty. The same key is sent on every retry attempt.
+func (r *Request) SetIdempotencyKey(key string) *Request {
+	if key == "" {
+		key = newUUID()
+	}
+	r.headers.Set("Idempotency-Key", key)
+	return r
+}
+
+// SetIfMatch sets the If-Match header; a bare etag is quoted
+func (r *Request) SetIfMatch(etag string) *Request {
+	r.headers.Set("If-Match", quoteETag(etag))
+	return r
+}
+
+// SetIfNoneMatch sets the If-None-Match header; a bare etag is quoted
+func (r *Request) SetIfNoneMatch(etag string) *Request {
+	r.headers.Set("If-None-Match", quoteETag(etag))
+	return r
+}
+
+// SetIfModifiedSince sets the If-Modified-Since header as an HTTP date
+func (r *Request) SetIfModifiedSince(t time.Time) *Request {
+	r.headers.Set("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+	return r
+}
+
+// SetIfUnmodifiedSince sets the If-Unmodified-Since header as an HTTP date
+func (r *Request) SetIfUnmodifiedSince(t time.Time) *Request {
+	r.headers.Set("If-Unmodified-Since", t.UTC().Format(http.TimeFormat))
+	return r
+}
+
 // SetQueryParam sets a query parameter for the request
 func (r *Request) SetQueryParam(key, value string) *Request {
 	r.queryParams.Set(key, value)
@@ -91,6 +183,49 @@ func (r *Request) SetQueryParams(params map[string]string) *Request {
 	return r
 }
 
+// SetQueryParamAdd adds a query parameter value, keeping any existing values for the key
+func (r *Request) SetQueryParamAdd(key, value string) *Request {
+	r.queryParams.Add(key, value)
+	return r
+}
+
+// AddQueryParams adds multiple values per key from a map, keeping existing values
+func (r *Request) AddQueryParams(params map[string][]string) *Request {
+	for k, values := range params {
+		for _, v := range values {
+			r.queryParams.Add(k, v)
+		}
+	}
+	return r
+}
+
+// SetQueryParamsSlice sets multiple values per key from a map, replacing existing values
+func (r *Request) SetQueryParamsSlice(params map[string][]string) *Request {
+	for k, values := range params {
+		r.queryParams[k] = append([]string(nil), values...)
+	}
+	return r
+}
+
+// SetQueryParamList sets key to values joined by sep as a single parameter
+// (e.g. ids=1,2,3), for APIs that expect CSV-style lists instead of repeated keys
+func (r *Request) SetQueryParamList(key string, values []string, sep string) *Request {
+	r.queryParams.Set(key, strings.Join(values, sep))
+	return r
+}
+
+// SetQueryParamsFromStruct sets query parameters from the fields of a struct.
+// Fields use the `url` tag, falling back to the `json` tag or the field name,
+// and are encoded like SetFormDataFromStruct except that slices of scalars
+// become repeated keys.
+// Encoding errors are returned when the request is executed.
+func (r *Request) SetQueryParamsFromStruct(v interface{}) *Request {
+	if err := encodeQueryStruct(r.queryParams, v); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to encode query params: %w", err)
+	}
+	return r
+}
+
 // SetQueryParamsFromValues sets query parameters from url.Values
 func (r *Request) SetQueryParamsFromValues(params url.Values) *Request {
 	for k, values := range params {
@@ -101,32 +236,47 @@ func (r *Request) SetQueryParamsFromValues(params url.Values) *Request {
 	return r
 }
 
-// SetQueryString sets the query string directly
+// SetQueryString sets the query string directly. A malformed query string
+// is reported when the request is executed or validated.
 func (r *Request) SetQueryString(query string) *Request {
-	values, err := url.ParseQuery(query)
-	if err == nil {
-		r.queryParams = values
+	if err := r.SetQueryStringE(query); err != nil && r.err == nil {
+		r.err = err
 	}
 	return r
 }
 
-// SetPathParam sets a path parameter for URL replacement
-func (r *Request) SetPathParam(key, value string) *Request {
-	if r.pathParams == nil {
-		r.pathParams = make(map[string]string)
+// SetQueryStringE sets the query string directly and returns any parse error
+func (r *Request) SetQueryStringE(query string) error {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("invalid query string: %w", err)
 	}
-	r.pathParams[key] = value
-	return r
+	r.queryParams = values
+	return nil
+}
+
+// SetPathParam sets a path parameter for URL replacement.
+// The value is path-escaped, so "a/b c" becomes a single "a%2Fb%20c" segment
+// and ".." stays a literal "%2E%2E" segment.
+func (r *Request) SetPathParam(key, value string) *Request {
+	return r.SetRawPathParam(key, escapePathParam(value))
 }
 
 // SetPathParams sets multiple path parameters from a map
 func (r *Request) SetPathParams(params map[string]string) *Request {
+	for k, v := range params {
+		r.SetPathParam(k, v)
+	}
+	return r
+}
+
+// SetRawPathParam sets a path parameter without escaping it, for values
+// that intentionally span several path segments
+func (r *Request) SetRawPathParam(key, value string) *Request {
 	if r.pathParams == nil {
 		r.pathParams = make(map[string]string)
 	}
-	for k, v := range params {
-		r.pathParams[k] = v
-	}
+	r.pathParams[key] = value
 	return r
 }
 
@@ -148,6 +298,19 @@ func (r *Request) SetFormDataFromValues(data url.Values) *Request {
 	return r
 }
 
+// SetFormDataArray adds multiple values for a form field, keeping existing values
+func (r *Request) SetFormDataArray(key string, values ...string) *Request {
+	for _, v := range values {
+		r.formData.Add(key, v)
+	}
+	return r
+}
+
+// SetBodyForm sets the form body from url.Values, keeping multiple values per key
+func (r *Request) SetBodyForm(data url.Values) *Request {
+	return r.SetFormDataFromValues(data)
+}
+
 // SetBody sets the request body
 func (r *Request) SetBody(body interface{}) *Request {
 	r.body = body
@@ -166,12 +329,30 @@ func (r *Request) SetBodyString(body string) *Request {
 	return r
 }
 
+// SetContentType sets the Content-Type sent with the request body, replacing
+// the one derived from the body type. It is useful for raw bytes, strings and
+// readers such as PDFs or images, which otherwise get no Content-Type. For
+// multipart bodies only another multipart type such as multipart/related is
+// used, keeping the generated boundary. A Content-Type header set with
+// SetHeader still takes precedence.
+func (r *Request) SetContentType(contentType string) *Request {
+	r.contentType = contentType
+	return r
+}
+
 // SetBodyReader sets the request body from an io.Reader
 func (r *Request) SetBodyReader(body io.Reader) *Request {
 	r.body = body
 	return r
 }
 
+// SetBodyReaderWithSize sets a streaming body of a known size, so it is sent
+// with a Content-Length and upload progress reports an accurate total
+func (r *Request) SetBodyReaderWithSize(body io.Reader, size int64) *Request {
+	r.body = &sizedReader{Reader: body, size: size}
+	return r
+}
+
 // SetBodyJSON sets the request body as JSON
 func (r *Request) SetBodyJSON(body interface{}) *Request {
 	r.body = body
@@ -186,6 +367,20 @@ func (r *Request) SetBodyXML(body interface{}) *Request {
 	return r
 }
 
+// SetBodyMsgPack sets the request body as MessagePack
+func (r *Request) SetBodyMsgPack(body interface{}) *Request {
+	r.body = body
+	r.bodyType = "msgpack"
+	return r
+}
+
+// SetBodyProto sets the request body as a protobuf message
+func (r *Request) SetBodyProto(msg proto.Message) *Request {
+	r.body = msg
+	r.bodyType = "proto"
+	return r
+}
+
 // SetBasicAuth sets basic authentication
 func (r *Request) SetBasicAuth(username, password string) *Request {
 	r.basicAuth.username = username
@@ -204,6 +399,33 @@ func (r *Request) SetAuthToken(token string) *Request {
 	return r.SetBearerToken(token)
 }
 
+// SetAWSSigV4 signs the request with AWS Signature Version 4.
+// The signature is recomputed on every attempt, including retries.
+func (r *Request) SetAWSSigV4(accessKey, secretKey, sessionToken, region, service string) *Request {
+	r.awsSigV4 = &awsSigV4{
+		accessKey:    accessKey,
+		secretKey:    secretKey,
+		sessionToken: sessionToken,
+		region:       region,
+		service:      service,
+	}
+	return r
+}
+
+// SetAWSSigV4Presign signs the request in the query string instead of the
+// Authorization header, producing a presigned URL valid for expires.
+// It must be called after SetAWSSigV4.
+func (r *Request) SetAWSSigV4Presign(expires time.Duration) *Request {
+	if r.awsSigV4 == nil {
+		if r.err == nil {
+			r.err = fmt.Errorf("SetAWSSigV4Presign requires SetAWSSigV4")
+		}
+		return r
+	}
+	r.awsSigV4.presignExpires = expires
+	return r
+}
+
 // SetCookies sets cookies for the request
 func (r *Request) SetCookies(cookies ...*http.Cookie) *Request {
 	r.cookies = append(r.cookies, cookies...)
@@ -216,6 +438,18 @@ func (r *Request) SetCookie(cookie *http.Cookie) *Request {
 	return r
 }
 
+// SetSimpleCookies sets cookies from name/value pairs
+func (r *Request) SetSimpleCookies(cookies map[string]string) *Request {
+	return r.SetCookies(simpleCookies(cookies)...)
+}
+
+// WithoutCookieJar bypasses the client's cookie jar for this request: only
+// cookies set explicitly are sent and Set-Cookie responses are not stored
+func (r *Request) WithoutCookieJar() *Request {
+	r.noCookieJar = true
+	return r
+}
+
 // SetSuccessResult sets the struct to unmarshal successful response into
 func (r *Request) SetSuccessResult(result interface{}) *Request {
 	r.successResult = result
@@ -245,18 +479,109 @@ func (r *Request) SetTracer(tracer trace.Tracer, spanName string) *Request {
 	return r
 }
 
-// SetOutput sets the file path to save the response body
+// SetJSONDisallowUnknownFields overrides the client's unknown JSON field handling for this request
+func (r *Request) SetJSONDisallowUnknownFields(enable bool) *Request {
+	r.strictJSON = &enable
+	return r
+}
+
+// SetJSONMarshal overrides the client's JSON marshal function for this request
+func (r *Request) SetJSONMarshal(fn func(v interface{}) ([]byte, error)) *Request {
+	r.jsonMarshal = fn
+	return r
+}
+
+// SetJSONUnmarshal overrides the client's JSON unmarshal function for this
+// request's results. It takes precedence over the client's JSON decoding options.
+func (r *Request) SetJSONUnmarshal(fn func(data []byte, v interface{}) error) *Request {
+	r.jsonUnmarshal = fn
+	return r
+}
+
+// SetXMLMarshal overrides the client's XML marshal function for this request
+func (r *Request) SetXMLMarshal(fn func(v interface{}) ([]byte, error)) *Request {
+	r.xmlMarshal = fn
+	return r
+}
+
+// SetXMLUnmarshal overrides the client's XML unmarshal function for this request's results
+func (r *Request) SetXMLUnmarshal(fn func(data []byte, v interface{}) error) *Request {
+	r.xmlUnmarshal = fn
+	return r
+}
+
+// SetRetryCount overrides the client's retry count for this request
+func (r *Request) SetRetryCount(count int) *Request {
+	r.retryCount = &count
+	return r
+}
+
+// SetRetryInterval overrides the client's retry interval for this request
+func (r *Request) SetRetryInterval(interval time.Duration) *Request {
+	r.retryInterval = &interval
+	return r
+}
+
+// SetRetryCondition overrides the client's retry condition for this request
+func (r *Request) SetRetryCondition(condition RetryConditionFunc) *Request {
+	return r.SetRetryConditionFull(fullRetryCondition(condition))
+}
+
+// SetRetryConditionFull overrides the client's retry condition for this request
+// with one that can also inspect the attempt number and the elapsed time
+func (r *Request) SetRetryConditionFull(condition RetryConditionFullFunc) *Request {
+	r.retryCondition = condition
+	return r
+}
+
+// SetOutput sets the file path to stream a successful (2xx) response body into,
+// so Response.Body stays empty
 func (r *Request) SetOutput(filePath string) *Request {
 	r.downloadPath = filePath
 	return r
 }
 
-// SetUploadCallback sets a callback function for upload progress
+// SetOutputResume sets the file path to save the response body, resuming a
+// partial download with a Range request if the file already exists. A file
+// that is already complete is reported as a 200 response with an empty body.
+func (r *Request) SetOutputResume(filePath string) *Request {
+	r.downloadPath = filePath
+	r.resumeOutput = true
+	return r
+}
+
+// SetWriter streams a successful (2xx) response body into w as it is read
+// instead of buffering it, so Response.Body stays empty. Other responses are
+// buffered as usual so error results can still be bound. SetOutput is ignored
+// when a writer is set.
+func (r *Request) SetWriter(w io.Writer) *Request {
+	r.writer = w
+	return r
+}
+
+// EnableStreamResponse leaves the response body unread so it can be consumed
+// incrementally with Response.Raw or Response.DecodeStream. Result binding,
+// caching and SetOutput are skipped, and the caller must close the body.
+func (r *Request) EnableStreamResponse() *Request {
+	r.stream = true
+	return r
+}
+
+// SetUploadCallback sets a callback function for upload progress.
+// total is -1 when the body size is unknown and the body is sent chunked.
+// Each copy of the body that is sent, e.g. by a hedge or a redirect, reports
+// its own progress from zero, possibly concurrently.
 func (r *Request) SetUploadCallback(callback func(written int64, total int64)) *Request {
 	r.uploadCallback = callback
 	return r
 }
 
+// SetTransport routes only this request through the given RoundTripper
+func (r *Request) SetTransport(rt http.RoundTripper) *Request {
+	r.transport = rt
+	return r
+}
+
 // Get executes a GET request
 func (r *Request) Get(url ...string) (*Response, error) {
 	if len(url) > 0 {
@@ -322,6 +647,9 @@ func (r *Request) Options(url ...string) (*Response, error) {
 
 // Execute executes the request
 func (r *Request) Execute() (*Response, error) {
+	if r.client.useSingleFlight(r) {
+		return r.client.executeShared(r)
+	}
 	return r.client.execute(r)
 }
 
@@ -432,6 +760,12 @@ func (r *Request) Clone() *Request {
 	cookies := make([]*http.Cookie, len(r.cookies))
 	copy(cookies, r.cookies)
 
+	var sigV4 *awsSigV4
+	if r.awsSigV4 != nil {
+		cfg := *r.awsSigV4
+		sigV4 = &cfg
+	}
+
 	return &Request{
 		client:         r.client,
 		method:         r.method,
@@ -443,19 +777,48 @@ func (r *Request) Clone() *Request {
 		formData:       formData,
 		body:           r.body,
 		bodyType:       r.bodyType,
+		contentType:    r.contentType,
 		cookies:        cookies,
+		userAgent:      r.userAgent,
 		basicAuth:      r.basicAuth,
 		bearerToken:    r.bearerToken,
 		successResult:  r.successResult,
 		errorResult:    r.errorResult,
 		downloadPath:   r.downloadPath,
+		resumeOutput:   r.resumeOutput,
+		writer:         r.writer,
+		retryCount:     r.retryCount,
+		retryInterval:  r.retryInterval,
+		retryCondition: r.retryCondition,
+		awsSigV4:       sigV4,
+		timeout:        r.timeout,
+		deadline:       r.deadline,
+		transport:      r.transport,
+		stream:         r.stream,
+		noCookieJar:    r.noCookieJar,
+		baseURL:        r.baseURL,
+		strictJSON:     r.strictJSON,
+		jsonMarshal:    r.jsonMarshal,
+		jsonUnmarshal:  r.jsonUnmarshal,
+		xmlMarshal:     r.xmlMarshal,
+		xmlUnmarshal:   r.xmlUnmarshal,
 		uploadCallback: r.uploadCallback,
+		tracer:         r.tracer,
+		spanName:       r.spanName,
+		err:            r.err,
 	}
 }
 
+// BuildHTTPRequest returns the *http.Request exactly as it would be sent,
+// without sending it. Middlewares are not run, and a copy of the request is
+// prepared so a generated request ID or fetched token is not kept on r.
+func (r *Request) BuildHTTPRequest() (*http.Request, error) {
+	return r.client.prepareRequest(r.Clone())
+}
+
 // URL returns the final request URL (after path parameter replacement)
 func (r *Request) URL() string {
-	u, err := r.client.buildURL(r.url, r.pathParams, r.queryParams)
+	u, err := r.client.buildURL(r)
 	if err != nil {
 		return r.url
 	}
@@ -480,6 +843,9 @@ func (r *Request) Validate() error {
 	if r.url == "" {
 		return fmt.Errorf("URL is required")
 	}
+	if r.err != nil {
+		return r.err
+	}
 	return nil
 }
 
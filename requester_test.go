@@ -1,10 +1,46 @@
 package cumi
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
 type User struct {
@@ -373,7 +409,7 @@ func TestDefaultContentType(t *testing.T) {
 	}))
 	defer server.Close()
 
-	// Test 1: No explicit Content-Type should default to application/json
+	// Test 1: Bodyless request without explicit Content-Type should omit it
 	client := NewClient()
 	resp, err := client.Http().Get(server.URL)
 	if err != nil {
@@ -385,8 +421,8 @@ func TestDefaultContentType(t *testing.T) {
 		t.Fatalf("Failed to unmarshal response: %v", err)
 	}
 
-	if result["content_type"] != "application/json" {
-		t.Errorf("Expected default Content-Type 'application/json', got '%s'", result["content_type"])
+	if result["content_type"] != "" {
+		t.Errorf("Expected no Content-Type on bodyless request, got '%s'", result["content_type"])
 	}
 
 	// Test 2: Explicit Content-Type should override default
@@ -405,4 +441,3626 @@ func TestDefaultContentType(t *testing.T) {
 	if result2["content_type"] != "text/plain" {
 		t.Errorf("Expected Content-Type 'text/plain', got '%s'", result2["content_type"])
 	}
+
+	// Test 3: Body without a body type should use the client default Content-Type
+	resp3, err := client.Http().
+		SetBodyString(`{"name":"John"}`).
+		Post(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result3 map[string]string
+	if err := json.Unmarshal(resp3.Body(), &result3); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if result3["content_type"] != "application/json" {
+		t.Errorf("Expected default Content-Type 'application/json', got '%s'", result3["content_type"])
+	}
+
+	// Test 4: Body type is used when the client has no Content-Type
+	resp4, err := NewClientWithConfig(&Config{}).Http().
+		SetBodyXML(User{Name: "John"}).
+		Post(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result4 map[string]string
+	if err := json.Unmarshal(resp4.Body(), &result4); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if result4["content_type"] != "application/xml" {
+		t.Errorf("Expected Content-Type 'application/xml', got '%s'", result4["content_type"])
+	}
+
+	// Test 5: The client Content-Type takes precedence over the body type
+	resp5, err := NewClient().
+		SetCommonHeader("Content-Type", "application/vnd.api+json").
+		Post(server.URL).
+		SetBodyJSON(User{Name: "John"}).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result5 map[string]string
+	if err := json.Unmarshal(resp5.Body(), &result5); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if result5["content_type"] != "application/vnd.api+json" {
+		t.Errorf("Expected Content-Type 'application/vnd.api+json', got '%s'", result5["content_type"])
+	}
+}
+
+func TestRepeatedQueryParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.URL.Query())
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Http().
+		SetQueryParamAdd("id", "1").
+		SetQueryParamAdd("id", "2").
+		AddQueryParams(map[string][]string{"tag": {"a", "b"}}).
+		SetQueryParamsSlice(map[string][]string{"sort": {"name", "age"}}).
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result map[string][]string
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if len(result["id"]) != 2 || result["id"][0] != "1" || result["id"][1] != "2" {
+		t.Errorf("Expected id=[1 2], got %v", result["id"])
+	}
+	if len(result["tag"]) != 2 {
+		t.Errorf("Expected tag=[a b], got %v", result["tag"])
+	}
+	if len(result["sort"]) != 2 {
+		t.Errorf("Expected sort=[name age], got %v", result["sort"])
+	}
+}
+
+func TestSetQueryParamList(t *testing.T) {
+	var rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	_, err := NewClient().Http().
+		SetQueryParam("ids", "0").
+		SetQueryParamList("ids", []string{"1", "2", "3"}, ",").
+		SetQueryParamList("tags", []string{"a", "b"}, "|").
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	query, _ := url.ParseQuery(rawQuery)
+	if ids := query["ids"]; len(ids) != 1 || ids[0] != "1,2,3" {
+		t.Errorf("Expected ids=1,2,3, got %v", ids)
+	}
+	if tags := query["tags"]; len(tags) != 1 || tags[0] != "a|b" {
+		t.Errorf("Expected tags=a|b, got %v", tags)
+	}
+}
+
+func TestSetQueryParamsFromStruct(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.URL.Query())
+	}))
+	defer server.Close()
+
+	type Filter struct {
+		Status string `url:"status"`
+	}
+	type Paging struct {
+		Size int `url:"size"`
+	}
+	type Query struct {
+		Paging
+		Name    string            `url:"name"`
+		Page    int               `json:"page"`
+		IDs     []int             `url:"id"`
+		Empty   string            `url:"empty,omitempty"`
+		Skipped string            `url:"-"`
+		Since   time.Time         `url:"since"`
+		Filter  Filter            `url:"filter"`
+		Labels  map[string]string `url:"labels"`
+		Limit   int
+	}
+
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	client := NewClient()
+	resp, err := client.Http().
+		SetQueryParamsFromStruct(Query{
+			Name:    "john",
+			Page:    2,
+			IDs:     []int{1, 2},
+			Skipped: "x",
+			Since:   since,
+			Filter:  Filter{Status: "active"},
+			Labels:  map[string]string{"team": "core"},
+			Limit:   10,
+			Paging:  Paging{Size: 50},
+		}).
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result map[string][]string
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	expected := map[string][]string{
+		"name":           {"john"},
+		"page":           {"2"},
+		"id":             {"1", "2"},
+		"since":          {since.Format(time.RFC3339)},
+		"filter[status]": {"active"},
+		"labels[team]":   {"core"},
+		"Limit":          {"10"},
+		"size":           {"50"},
+	}
+	if len(result) != len(expected) {
+		t.Errorf("Expected %d params, got %v", len(expected), result)
+	}
+	for k, v := range expected {
+		if strings.Join(result[k], ",") != strings.Join(v, ",") {
+			t.Errorf("Expected %s=%v, got %v", k, v, result[k])
+		}
+	}
+
+	_, err = client.Http().SetQueryParamsFromStruct("not a struct").Get(server.URL)
+	if err == nil {
+		t.Errorf("Expected error for non-struct query params")
+	}
+}
+
+func TestResponseCacheETag(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(User{Name: "John", Age: 30})
+	}))
+	defer server.Close()
+
+	client := NewClient().SetCache(NewMemoryCache(), time.Minute)
+	resp, err := client.Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.IsFromCache() {
+		t.Errorf("Expected first response not to be from cache")
+	}
+
+	var user User
+	resp2, err := client.Http().SetSuccessResult(&user).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp2.IsFromCache() || resp2.StatusCode != 200 {
+		t.Errorf("Expected cached 200 response, got %d (cached=%v)", resp2.StatusCode, resp2.IsFromCache())
+	}
+	if user.Name != "John" {
+		t.Errorf("Expected name=John from cached body, got %s", user.Name)
+	}
+	if hits != 2 {
+		t.Errorf("Expected 2 server hits, got %d", hits)
+	}
+}
+
+func TestResponseCacheKeyAndVary(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The body depends on the request, so any wrong cache hit is visible
+		tag := `"` + r.Header.Get("Authorization") + "|" + r.Header.Get("Accept") + "|" + r.Header.Get("Accept-Language") + `"`
+		if r.Header.Get("If-None-Match") == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Vary", "Accept-Language")
+		w.Write([]byte(tag))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetCache(NewMemoryCache(), time.Minute)
+	get := func(token, accept, language string) *Response {
+		req := client.Get(server.URL).SetBearerToken(token).SetHeader("Accept", accept)
+		if language != "" {
+			req.SetHeader("Accept-Language", language)
+		}
+		resp, err := req.Execute()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		want := `"Bearer ` + token + "|" + accept + "|" + language + `"`
+		if resp.String() != want {
+			t.Errorf("Expected body %s, got %s", want, resp.String())
+		}
+		return resp
+	}
+
+	get("alice", "application/json", "")
+	if get("bob", "application/json", "").IsFromCache() {
+		t.Errorf("Expected another Authorization header not to use the cached entry")
+	}
+	if get("alice", "text/csv", "").IsFromCache() {
+		t.Errorf("Expected another Accept header not to use the cached entry")
+	}
+	if !get("alice", "application/json", "").IsFromCache() {
+		t.Errorf("Expected the same headers to use the cached entry")
+	}
+	if get("alice", "application/json", "id").IsFromCache() {
+		t.Errorf("Expected a header named by Vary not to use the cached entry")
+	}
+	if !get("alice", "application/json", "id").IsFromCache() {
+		t.Errorf("Expected matching Vary headers to use the cached entry")
+	}
+
+	// Cookie sessions, set on the request or in the jar, get their own entries
+	cookieServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cookies []string
+		for _, cookie := range r.Cookies() {
+			cookies = append(cookies, cookie.Name+"="+cookie.Value)
+		}
+		tag := `"` + strings.Join(cookies, ";") + `"`
+		if r.Header.Get("If-None-Match") == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", tag)
+		w.Write([]byte(tag))
+	}))
+	defer cookieServer.Close()
+
+	store := NewMemoryCache()
+	alice := NewClient().SetCache(store, time.Minute)
+	bob := NewClient().SetCache(store, time.Minute)
+	alice.Get(cookieServer.URL).SetCookie(&http.Cookie{Name: "session", Value: "alice"}).Execute()
+	resp, _ := bob.Get(cookieServer.URL).SetCookie(&http.Cookie{Name: "session", Value: "bob"}).Execute()
+	if resp.IsFromCache() || resp.String() != `"session=bob"` {
+		t.Errorf("Expected another cookie not to use the cached entry, got %s", resp.String())
+	}
+	u, _ := url.Parse(cookieServer.URL)
+	alice.GetCookieJar().SetCookies(u, []*http.Cookie{{Name: "jar", Value: "alice"}})
+	bob.GetCookieJar().SetCookies(u, []*http.Cookie{{Name: "jar", Value: "bob"}})
+	alice.Get(cookieServer.URL).Execute()
+	resp, _ = bob.Get(cookieServer.URL).Execute()
+	if resp.IsFromCache() || resp.String() != `"jar=bob"` {
+		t.Errorf("Expected another jar cookie not to use the cached entry, got %s", resp.String())
+	}
+
+	// Entries varying on everything are never reused, whatever the store holds
+	entry := &CacheEntry{Body: []byte("stale"), Header: http.Header{"Vary": {"Accept, *"}}, ETag: `"x"`}
+	if varyMatches(entry, httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Errorf("Expected Vary: * never to match")
+	}
+}
+
+func TestCloneShareCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+	if _, err := client.Http().Get("/login"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	resp, err := client.Clone().Http().Get("/me")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "" {
+		t.Errorf("Expected clone without shared jar to have no session, got %q", resp.String())
+	}
+
+	client.EnableShareCookieJar()
+	shared := client.Clone()
+	if shared.GetCookieJar() != client.GetCookieJar() {
+		t.Errorf("Expected clone to share the parent cookie jar")
+	}
+	resp, err = shared.Http().Get("/me")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "abc" {
+		t.Errorf("Expected shared session cookie abc, got %q", resp.String())
+	}
+}
+
+func TestSetOutputResume(t *testing.T) {
+	content := "0123456789abcdefghij"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.txt", time.Time{}, strings.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(content[:8]), 0o644); err != nil {
+		t.Fatalf("Failed to write partial file: %v", err)
+	}
+
+	client := NewClient()
+	resp, err := client.Http().SetOutputResume(path).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Errorf("Expected status 206, got %d", resp.StatusCode)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Expected %q, got %q", content, string(data))
+	}
+	if len(resp.Body()) != 0 || resp.Size() != int64(len(content)-8) {
+		t.Errorf("Expected the body to be streamed to the file, got %d buffered bytes and size %d", len(resp.Body()), resp.Size())
+	}
+
+	// Resuming a complete file gets a 416 from the server, which is a success
+	resp, err = client.Http().SetOutputResume(path).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error for a complete file, got %v", err)
+	}
+	if !resp.IsSuccess() || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected a complete file to succeed, got status %d", resp.StatusCode)
+	}
+	if data, _ := os.ReadFile(path); string(data) != content {
+		t.Errorf("Expected the complete file to be kept, got %q", string(data))
+	}
+}
+
+func TestPerRequestRetryOverride(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient().SetRetryCount(0)
+	_, err := client.Http().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if hits != 3 {
+		t.Errorf("Expected 3 attempts, got %d", hits)
+	}
+
+	hits = 0
+	_, err = client.Http().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool { return false }).
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if hits != 1 {
+		t.Errorf("Expected 1 attempt with retry condition override, got %d", hits)
+	}
+}
+
+func TestOnRetryHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	var attempts []int
+	client := NewClient().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		OnRetry(func(resp *Response, err error, attempt int) {
+			if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+				t.Errorf("Expected retry to be triggered by a 429 response")
+			}
+			attempts = append(attempts, attempt)
+		})
+
+	if _, err := client.Http().Get(server.URL); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(attempts) != 2 || attempts[0] != 1 || attempts[1] != 2 {
+		t.Errorf("Expected retry hook for attempts [1 2], got %v", attempts)
+	}
+}
+
+func TestBeforeRequestMiddlewareModifiesRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"header": r.Header.Get("X-Signed"),
+			"path":   r.URL.Path,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		SetBaseURL(server.URL).
+		OnBeforeRequest(func(c *Client, r *Request) error {
+			r.SetHeader("X-Signed", "yes")
+			r.url = "/rewritten"
+			return nil
+		})
+
+	resp, err := client.Http().Get("/original")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if result["header"] != "yes" {
+		t.Errorf("Expected X-Signed header from middleware, got %q", result["header"])
+	}
+	if result["path"] != "/rewritten" {
+		t.Errorf("Expected path /rewritten from middleware, got %q", result["path"])
+	}
+}
+
+func TestBeforeRequestMiddlewareRunsOncePerRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	once, perAttempt := 0, 0
+	client := NewClient().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		OnBeforeRequest(func(c *Client, r *Request) error {
+			once++
+			return nil
+		}).
+		OnBeforeRequestPerAttempt(func(c *Client, r *Request) error {
+			perAttempt++
+			return nil
+		})
+
+	if _, err := client.Http().Get(server.URL); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if once != 1 {
+		t.Errorf("Expected before request middleware to run once, got %d", once)
+	}
+	if perAttempt != 3 {
+		t.Errorf("Expected per-attempt middleware to run 3 times, got %d", perAttempt)
+	}
+}
+
+func TestAfterResponseMiddlewareOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var order []string
+	record := func(name string) ResponseMiddleware {
+		return func(c *Client, r *Response) error {
+			order = append(order, name)
+			return nil
+		}
+	}
+
+	client := NewClient().
+		OnAfterResponse(record("b")).
+		OnAfterResponse(record("c")).
+		PrependAfterResponse(record("a"))
+
+	clone := client.Clone()
+	if _, err := clone.Http().Get(server.URL); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(order, ",") != "a,b,c" {
+		t.Errorf("Expected clone middleware order a,b,c, got %v", order)
+	}
+
+	order = nil
+	client.ResetMiddleware().OnAfterResponse(record("z"))
+	if _, err := client.Http().Get(server.URL); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Join(order, ",") != "z" {
+		t.Errorf("Expected only z after reset, got %v", order)
+	}
+}
+
+func TestAWSSigV4Signature(t *testing.T) {
+	cfg := &awsSigV4{
+		accessKey: "AKIDEXAMPLE",
+		secretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		region:    "us-east-1",
+		service:   "service",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	tests := []struct {
+		url       string
+		signature string
+	}{
+		{"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, tt := range tests {
+		httpReq, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+		if err := signAWSSigV4(httpReq, cfg, now); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		auth := httpReq.Header.Get("Authorization")
+		if !strings.HasSuffix(auth, "SignedHeaders=host;x-amz-date, Signature="+tt.signature) {
+			t.Errorf("Unexpected Authorization for %s: %s", tt.url, auth)
+		}
+		if httpReq.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+			t.Errorf("Expected X-Amz-Date 20150830T123600Z, got %s", httpReq.Header.Get("X-Amz-Date"))
+		}
+	}
+}
+
+func TestResponseJSONPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"items":[{"id":42,"name":"first","active":true}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	id, err := resp.JSONPathInt("data.items[0].id")
+	if err != nil || id != 42 {
+		t.Errorf("Expected id=42, got %d (err=%v)", id, err)
+	}
+	name, err := resp.JSONPathString("data.items[0].name")
+	if err != nil || name != "first" {
+		t.Errorf("Expected name=first, got %q (err=%v)", name, err)
+	}
+	if _, err := resp.JSONPath("data.items[1].id"); err == nil {
+		t.Errorf("Expected error for out of range index")
+	}
+	if _, err := resp.JSONPath("data.missing"); err == nil {
+		t.Errorf("Expected error for missing key")
+	}
+	if _, err := resp.JSONPathString("data.items[0].id"); err == nil {
+		t.Errorf("Expected error for type mismatch")
+	}
+}
+
+func TestRegisterDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/yaml" {
+			w.Header().Set("Content-Type", "application/yaml")
+		} else {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		}
+		w.Write([]byte("John,30"))
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		SetBaseURL(server.URL).
+		RegisterDecoder("text/csv", func(data []byte, v interface{}) error {
+			parts := strings.Split(string(data), ",")
+			user := v.(*User)
+			user.Name = parts[0]
+			return nil
+		})
+
+	var user User
+	if _, err := client.Http().SetSuccessResult(&user).Get("/csv"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if user.Name != "John" {
+		t.Errorf("Expected name=John from CSV decoder, got %q", user.Name)
+	}
+
+	resp, err := client.Http().SetSuccessResult(&user).Get("/yaml")
+	if err == nil {
+		t.Errorf("Expected error for unregistered content type")
+	}
+	if err := resp.Unmarshal(&user); err == nil {
+		t.Errorf("Expected Unmarshal error for unregistered content type")
+	}
+}
+
+func TestMsgPackBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/msgpack" {
+			t.Errorf("Expected Content-Type application/msgpack, got %s", r.Header.Get("Content-Type"))
+		}
+		var user User
+		body, _ := io.ReadAll(r.Body)
+		if err := MsgPackUnmarshal(body, &user); err != nil {
+			t.Errorf("Failed to decode msgpack request: %v", err)
+		}
+		user.Age++
+		data, _ := MsgPackMarshal(user)
+		w.Header().Set("Content-Type", "application/msgpack")
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	var result User
+	client := NewClientWithConfig(&Config{})
+	_, err := client.Http().
+		SetBodyMsgPack(User{Name: "John", Age: 30}).
+		SetSuccessResult(&result).
+		Post(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Name != "John" || result.Age != 31 {
+		t.Errorf("Expected John/31, got %+v", result)
+	}
+}
+
+func TestMsgPackEdgeCases(t *testing.T) {
+	// A map keyed by an array must fail instead of panicking
+	var generic interface{}
+	if err := MsgPackUnmarshal([]byte{0x81, 0x91, 0x01, 0x02}, &generic); err == nil {
+		t.Errorf("Expected an error for an unhashable map key")
+	}
+
+	maxUint := []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	var i64 int64
+	if err := MsgPackUnmarshal(maxUint, &i64); err == nil {
+		t.Errorf("Expected an overflow error, got %d", i64)
+	}
+	var u64 uint64
+	if err := MsgPackUnmarshal(maxUint, &u64); err != nil || u64 != math.MaxUint64 {
+		t.Errorf("Expected MaxUint64, got %d (err=%v)", u64, err)
+	}
+	var i8 int8
+	if err := MsgPackUnmarshal([]byte{0xcc, 0xc8}, &i8); err == nil {
+		t.Errorf("Expected an overflow error, got %d", i8)
+	}
+
+	// Timestamp extension in its 32, 64 and 96-bit forms
+	for _, ts := range []time.Time{
+		time.Unix(1700000000, 0),
+		time.Unix(1700000000, 123456789),
+		time.Date(1960, 1, 2, 3, 4, 5, 6, time.UTC),
+	} {
+		data, err := MsgPackMarshal(struct{ At time.Time }{ts})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var decoded struct{ At time.Time }
+		if err := MsgPackUnmarshal(data, &decoded); err != nil || !decoded.At.Equal(ts) {
+			t.Errorf("Expected %v, got %v (err=%v)", ts, decoded.At, err)
+		}
+	}
+	var at time.Time
+	if err := MsgPackUnmarshal([]byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01}, &at); err != nil || !at.Equal(time.Unix(1, 0)) {
+		t.Errorf("Expected a fixext4 timestamp of 1s, got %v (err=%v)", at, err)
+	}
+
+	// Other extensions decode to MsgPackExt
+	if err := MsgPackUnmarshal([]byte{0xc7, 0x03, 0x05, 'a', 'b', 'c'}, &generic); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ext, ok := generic.(MsgPackExt); !ok || ext.Type != 5 || string(ext.Data) != "abc" {
+		t.Errorf("Expected extension 5 with data abc, got %#v", generic)
+	}
+	data, _ := MsgPackMarshal(MsgPackExt{Type: 7, Data: []byte{1, 2}})
+	if !bytes.Equal(data, []byte{0xd5, 0x07, 0x01, 0x02}) {
+		t.Errorf("Expected fixext2 encoding, got % x", data)
+	}
+
+	// Deep nesting is an error, not a stack overflow
+	deep := append(bytes.Repeat([]byte{0x91}, 1<<20), 0xc0)
+	if err := MsgPackUnmarshal(deep, &generic); err == nil || !strings.Contains(err.Error(), "max depth") {
+		t.Errorf("Expected a max depth error, got %v", err)
+	}
+	if err := MsgPackUnmarshal(append(bytes.Repeat([]byte{0x81, 0x01}, 100), 0xc0), &generic); err != nil {
+		t.Errorf("Expected moderate nesting to decode, got %v", err)
+	}
+}
+
+func FuzzMsgPackUnmarshal(f *testing.F) {
+	for _, v := range []interface{}{
+		nil, true, int64(-33), uint64(math.MaxUint64), 1.5, "text", []byte{1, 2},
+		[]interface{}{1, "a", []interface{}{nil}}, map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+		User{Name: "John", Age: 30}, time.Unix(1700000000, 5), MsgPackExt{Type: 7, Data: []byte{1}},
+	} {
+		data, err := MsgPackMarshal(v)
+		if err != nil {
+			f.Fatalf("Expected no error, got %v", err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var user User
+		MsgPackUnmarshal(data, &user)
+
+		var generic interface{}
+		if err := MsgPackUnmarshal(data, &generic); err != nil {
+			return
+		}
+		// Anything decoded encodes again, to something that decodes
+		encoded, err := MsgPackMarshal(generic)
+		if err != nil {
+			t.Fatalf("Expected decoded %#v to encode, got %v", generic, err)
+		}
+		if err := MsgPackUnmarshal(encoded, &generic); err != nil {
+			t.Fatalf("Expected re-encoded % x to decode, got %v", encoded, err)
+		}
+	})
+}
+
+func TestProtobufBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("Expected Content-Type application/x-protobuf, got %s", r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	var result wrapperspb.StringValue
+	client := NewClientWithConfig(&Config{})
+	resp, err := client.Http().
+		SetBodyProto(wrapperspb.String("hello")).
+		SetSuccessResult(&result).
+		Post(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.GetValue() != "hello" {
+		t.Errorf("Expected hello from success result, got %q", result.GetValue())
+	}
+
+	var echoed wrapperspb.StringValue
+	if err := resp.Protobuf(&echoed); err != nil || echoed.GetValue() != "hello" {
+		t.Errorf("Expected hello from Protobuf, got %q (err=%v)", echoed.GetValue(), err)
+	}
+}
+
+func TestMetricsHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	var metrics []RequestMetrics
+	client := NewClient().
+		SetBaseURL(server.URL).
+		SetMetricsHook(func(m RequestMetrics) {
+			metrics = append(metrics, m)
+		})
+
+	_, err := client.Http().
+		SetPathParam("id", "42").
+		SetBodyString("ping").
+		Post("/users/{id}")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(metrics) != 1 {
+		t.Fatalf("Expected 1 metrics event, got %d", len(metrics))
+	}
+	m := metrics[0]
+	if m.Method != http.MethodPost || m.PathTemplate != "/users/{id}" || m.StatusCode != 200 {
+		t.Errorf("Unexpected metrics: %+v", m)
+	}
+	if m.Attempts != 1 || m.BytesIn != 5 || m.BytesOut != 4 {
+		t.Errorf("Unexpected counters: %+v", m)
+	}
+	if m.Duration <= 0 || m.TimeToFirstByte <= 0 {
+		t.Errorf("Expected positive timings, got %+v", m)
+	}
+}
+
+func TestResponseTraceInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	info := resp.TraceInfo()
+	if info.TimeToFirstByte <= 0 || info.IsConnReused {
+		t.Errorf("Unexpected trace info for first request: %+v", info)
+	}
+
+	resp, err = client.Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.TraceInfo().IsConnReused {
+		t.Errorf("Expected second request to reuse the connection")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			t.Errorf("Expected client certificate")
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// Reuse the test server's certificate as both CA and client certificate
+	serverCert := server.TLS.Certificates[0]
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Certificate[0]})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(serverCert.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	client := NewClient().
+		SetRootCAs(certPEM).
+		SetClientCertificates(certPEM, keyPEM)
+	resp, err := client.Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	_, err = NewClient().SetClientCertificates([]byte("bad"), []byte("bad")).Http().Get(server.URL)
+	if err == nil {
+		t.Errorf("Expected error for invalid client certificate")
+	}
+}
+
+func TestCertPinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	sum := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+
+	client := NewClient().SetRootCAs(certPEM).SetCertPinning(hex.EncodeToString(sum[:]))
+	if _, err := client.Http().Get(server.URL); err != nil {
+		t.Fatalf("Expected pinned request to succeed, got %v", err)
+	}
+
+	wrong := strings.Repeat("00", sha256.Size)
+	client = NewClient().SetRootCAs(certPEM).SetCertPinning(wrong)
+	_, err := client.Http().Get(server.URL)
+	if !errors.Is(err, ErrCertPinMismatch) {
+		t.Errorf("Expected pinning mismatch error, got %v", err)
+	}
+}
+
+func TestCertPinningIgnoresUnverifiedCertificates(t *testing.T) {
+	// A certificate the attacker does not hold the key for, but whose
+	// fingerprint is pinned
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "pinned"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	pinnedDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	pinnedSum := sha256.Sum256(pinnedDER)
+
+	// The server presents its own valid certificate with the pinned one appended
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.StartTLS()
+	defer server.Close()
+	server.TLS.Certificates[0].Certificate = append(server.TLS.Certificates[0].Certificate, pinnedDER)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	_, err = NewClient().SetRootCAs(certPEM).SetCertPinning(hex.EncodeToString(pinnedSum[:])).Get(server.URL).Execute()
+	if !errors.Is(err, ErrCertPinMismatch) {
+		t.Errorf("Expected pinning mismatch for an appended certificate, got %v", err)
+	}
+
+	_, err = NewClient().EnableInsecureSkipVerify().SetCertPinning(hex.EncodeToString(pinnedSum[:])).Get(server.URL).Execute()
+	if !errors.Is(err, ErrCertPinMismatch) {
+		t.Errorf("Expected pinning mismatch without verification, got %v", err)
+	}
+}
+
+func TestEnvironmentProxyDefault(t *testing.T) {
+	client := NewClient()
+	transport := client.GetClient().Transport.(*http.Transport)
+	if transport.Proxy == nil {
+		t.Fatalf("Expected default transport to use the environment proxy")
+	}
+
+	client.DisableProxy()
+	if transport.Proxy != nil {
+		t.Errorf("Expected DisableProxy to clear the proxy")
+	}
+
+	client.UseEnvironmentProxy()
+	if transport.Proxy == nil {
+		t.Errorf("Expected UseEnvironmentProxy to restore the proxy")
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	client := NewClient().SetSOCKS5Proxy("127.0.0.1:1080", "user", "pass")
+	transport := client.GetClient().Transport.(*http.Transport)
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	proxyURL, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if proxyURL.Scheme != "socks5" || proxyURL.Host != "127.0.0.1:1080" {
+		t.Errorf("Expected socks5://127.0.0.1:1080, got %s", proxyURL)
+	}
+	if password, _ := proxyURL.User.Password(); proxyURL.User.Username() != "user" || password != "pass" {
+		t.Errorf("Expected proxy credentials user/pass, got %s", proxyURL.User)
+	}
+}
+
+func TestHostOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		DisableProxy().
+		SetHostOverride("api.example.test", server.Listener.Addr().String())
+	resp, err := client.Http().Get("http://api.example.test/ping")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "api.example.test" {
+		t.Errorf("Expected Host header api.example.test, got %q", resp.String())
+	}
+}
+
+func TestForceHTTPProtocol(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	resp, err := NewClient().SetRootCAs(certPEM).ForceHTTP2().Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.IsHTTP2() {
+		t.Errorf("Expected HTTP/2, got %s", resp.Proto)
+	}
+
+	resp, err = NewClient().SetRootCAs(certPEM).ForceHTTP1().Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.Proto != "HTTP/1.1" {
+		t.Errorf("Expected HTTP/1.1, got %s", resp.Proto)
+	}
+}
+
+func TestResponseRawRereadable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("payload"))
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().Http().Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		data, err := io.ReadAll(resp.Raw())
+		if err != nil || string(data) != "payload" {
+			t.Errorf("Expected payload on read %d, got %q (err=%v)", i+1, string(data), err)
+		}
+	}
+
+	data, err := io.ReadAll(resp.Response.Body)
+	if err != nil || string(data) != "payload" {
+		t.Errorf("Expected underlying body to be readable, got %q (err=%v)", string(data), err)
+	}
+}
+
+func TestResponseFinalURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().SetBaseURL(server.URL).Http().Get("/a")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.FinalURL() != server.URL+"/c" {
+		t.Errorf("Expected final URL %s/c, got %s", server.URL, resp.FinalURL())
+	}
+	if resp.RedirectCount() != 2 {
+		t.Errorf("Expected 2 redirects, got %d", resp.RedirectCount())
+	}
+}
+
+func TestRetryWaitHonorsContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := NewClient().
+		SetRetryCount(3).
+		SetRetryInterval(10 * time.Second).
+		OnRetry(func(resp *Response, err error, attempt int) {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				cancel()
+			}()
+		})
+
+	start := time.Now()
+	_, err := client.Http().SetContext(ctx).Get(server.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected prompt return after cancel, took %v", elapsed)
+	}
+}
+
+func TestFormDataArray(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.PostForm)
+	}))
+	defer server.Close()
+
+	resp, err := NewClientWithConfig(&Config{}).Http().
+		SetFormData(map[string]string{"name": "John"}).
+		SetFormDataArray("tags", "a", "b").
+		SetBodyForm(url.Values{"ids": {"1", "2"}}).
+		Post(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var result map[string][]string
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if strings.Join(result["tags"], ",") != "a,b" || strings.Join(result["ids"], ",") != "1,2" || result["name"][0] != "John" {
+		t.Errorf("Unexpected form values: %v", result)
+	}
+}
+
+func TestMaxResponseBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetRetryCount(2).SetMaxResponseBodySize(10)
+	_, err := client.Http().Get(server.URL)
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+
+	resp, err := client.SetMaxResponseBodySize(100).Http().Get(server.URL)
+	if err != nil || resp.Size() != 100 {
+		t.Errorf("Expected 100 byte body within limit, got %d (err=%v)", resp.Size(), err)
+	}
+}
+
+func TestResultCheckerVariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		checker func(*Response) ResultState
+		status  int
+		want    ResultState
+	}{
+		{"default 3xx", defaultResultChecker, 304, UnknownState},
+		{"success on 304", SuccessOn(304), 304, SuccessState},
+		{"success on falls back", SuccessOn(304), 404, ErrorState},
+		{"error on 204", ErrorOn(204), 204, ErrorState},
+		{"range includes 3xx", SuccessRange(200, 399), 302, SuccessState},
+		{"range excludes 1xx", SuccessRange(200, 399), 101, UnknownState},
+		{"chain", ChainResultCheckers(SuccessRange(100, 199), defaultResultChecker), 101, SuccessState},
+	}
+	for _, tt := range tests {
+		if got := tt.checker(&Response{StatusCode: tt.status}); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestCloneWithCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+	if _, err := client.Http().Get("/login"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	clone := client.CloneWithCookies()
+	if clone.GetCookieJar() == client.GetCookieJar() {
+		t.Errorf("Expected clone to have its own cookie jar")
+	}
+	resp, err := clone.Http().Get("/me")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "abc" {
+		t.Errorf("Expected copied session cookie abc, got %q", resp.String())
+	}
+
+	// Cookie attributes survive the copy
+	u, _ := url.Parse("https://app.example.com/api/v1")
+	client.GetCookieJar().SetCookies(u, []*http.Cookie{
+		{Name: "scoped", Value: "1", Domain: "example.com", Path: "/api", Secure: true, MaxAge: 3600},
+		{Name: "expired", Value: "1", Expires: time.Now().Add(-time.Hour)},
+	})
+	jar := client.CloneWithCookies().GetCookieJar()
+	for rawURL, want := range map[string]int{
+		"https://other.example.com/api/users": 1,
+		"http://app.example.com/api/users":    0,
+		"https://app.example.com/web":         0,
+	} {
+		u, _ := url.Parse(rawURL)
+		if got := len(jar.Cookies(u)); got != want {
+			t.Errorf("Expected %d cookies for %s, got %d", want, rawURL, got)
+		}
+	}
+
+	// Replaced cookies don't pile up
+	for i := 0; i < 100; i++ {
+		jar.SetCookies(u, []*http.Cookie{{Name: "counter", Value: strconv.Itoa(i)}})
+	}
+	if records := len(jar.(*cookieJar).records); records > 40 {
+		t.Errorf("Expected replaced cookies to be compacted, got %d records", records)
+	}
+
+	custom, _ := cookiejar.New(nil)
+	if client.SetCookieJar(custom).CloneWithCookies().GetCookieJar() != custom {
+		t.Errorf("Expected a custom jar to be shared with the clone")
+	}
+}
+
+func TestRequestContextHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	type ctxKey string
+	var seen interface{}
+	client := NewClient().OnBeforeRequest(func(c *Client, r *Request) error {
+		seen = r.Context().Value(ctxKey("trace"))
+		return nil
+	})
+
+	_, err := client.Http().
+		WithValue(ctxKey("trace"), "abc").
+		WithTimeout(20 * time.Millisecond).
+		Get(server.URL)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if seen != "abc" {
+		t.Errorf("Expected context value abc, got %v", seen)
+	}
+}
+
+func TestDefaultClientFunctions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	defer server.Close()
+
+	resp, err := Get(server.URL)
+	if err != nil || resp.String() != "GET " {
+		t.Errorf("Expected GET response, got %q (err=%v)", resp.String(), err)
+	}
+
+	resp, err = Post(server.URL, map[string]string{"name": "John"})
+	if err != nil || resp.String() != `POST {"name":"John"}` {
+		t.Errorf("Expected POST response with JSON body, got %q (err=%v)", resp.String(), err)
+	}
+}
+
+func TestDebugRedaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"password":"hunter2"}`))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	client := NewClient().
+		EnableDebug().
+		SetRedactHeaders("X-Api-Key").
+		SetBodyRedactor(func(body []byte) []byte {
+			return bytes.ReplaceAll(body, []byte("hunter2"), []byte("***"))
+		})
+	_, err := client.Http().
+		SetBearerToken("secret-token").
+		SetHeader("X-Api-Key", "secret-key").
+		Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	output := buf.String()
+	for _, secret := range []string{"secret-token", "secret-key", "hunter2"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %q to be redacted from debug output", secret)
+		}
+	}
+	if !strings.Contains(output, "[REDACTED]") {
+		t.Errorf("Expected redaction marker in debug output")
+	}
+}
+
+func TestDebugHook(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var events []DebugEvent
+	client := NewClient().
+		SetRetryCount(1).
+		SetRetryInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return resp != nil && resp.StatusCode == http.StatusServiceUnavailable
+		}).
+		SetDebugHook(func(event DebugEvent) {
+			events = append(events, event)
+		})
+	_, err := client.Http().SetBearerToken("secret-token").Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var types []DebugEventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []DebugEventType{DebugEventRequest, DebugEventResponse, DebugEventRetry, DebugEventRequest, DebugEventResponse}
+	if fmt.Sprint(types) != fmt.Sprint(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, types)
+	}
+	if events[0].Header.Get("Authorization") != "[REDACTED]" {
+		t.Errorf("Expected redacted Authorization header, got %q", events[0].Header.Get("Authorization"))
+	}
+	if events[1].StatusCode != http.StatusServiceUnavailable || events[4].Attempt != 2 {
+		t.Errorf("Unexpected response events: %+v, %+v", events[1], events[4])
+	}
+}
+
+func TestBuildHTTPRequest(t *testing.T) {
+	client := NewClient().
+		SetBaseURL("https://api.example.com").
+		SetCommonHeader("X-Client", "cumi")
+
+	httpReq, err := client.Put("/users/{id}").
+		SetPathParam("id", "42").
+		SetQueryParam("expand", "true").
+		SetBasicAuth("user", "pass").
+		SetBodyJSON(map[string]string{"name": "John"}).
+		BuildHTTPRequest()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if httpReq.Method != http.MethodPut || httpReq.URL.String() != "https://api.example.com/users/42?expand=true" {
+		t.Errorf("Unexpected request line: %s %s", httpReq.Method, httpReq.URL)
+	}
+	if httpReq.Header.Get("X-Client") != "cumi" || httpReq.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Unexpected headers: %v", httpReq.Header)
+	}
+	if user, pass, ok := httpReq.BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("Expected basic auth to be set")
+	}
+	body, _ := io.ReadAll(httpReq.Body)
+	if string(body) != `{"name":"John"}` {
+		t.Errorf("Unexpected body %q", body)
+	}
+
+	// Building leaves no request ID or fetched token behind on the request
+	tokens := 0
+	client.EnableRequestID("X-Request-ID").
+		SetBearerTokenFunc(func(ctx context.Context) (string, error) {
+			tokens++
+			return fmt.Sprintf("token-%d", tokens), nil
+		})
+	req := client.Get("/me")
+	first, _ := req.BuildHTTPRequest()
+	second, _ := req.BuildHTTPRequest()
+	if req.requestID != "" || req.fetchedToken != "" {
+		t.Errorf("Expected the request to be unchanged, got request ID %q and token %q", req.requestID, req.fetchedToken)
+	}
+	if first.Header.Get("X-Request-ID") == second.Header.Get("X-Request-ID") {
+		t.Errorf("Expected each build to get its own request ID")
+	}
+	if first.Header.Get("Authorization") != "Bearer token-1" || second.Header.Get("Authorization") != "Bearer token-2" {
+		t.Errorf("Expected each build to fetch a token, got %q and %q", first.Header.Get("Authorization"), second.Header.Get("Authorization"))
+	}
+}
+
+func TestRequestSetTransport(t *testing.T) {
+	mock := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"name":"John"}`)),
+			Request:    req,
+		}, nil
+	})
+
+	client := NewClient()
+	var user struct {
+		Name string `json:"name"`
+	}
+	resp, err := client.Http().
+		SetTransport(mock).
+		SetSuccessResult(&user).
+		Get("http://unreachable.invalid/users/1")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.IsSuccess() || user.Name != "John" {
+		t.Errorf("Expected mocked result, got status %d and name %q", resp.StatusCode, user.Name)
+	}
+	if _, ok := client.GetClient().Transport.(roundTripperFunc); ok {
+		t.Errorf("Expected client transport to be unchanged")
+	}
+}
+
+func TestSetQueryStringInvalid(t *testing.T) {
+	client := NewClient()
+
+	req := client.Get("http://example.com").SetQueryString("a=1&b=%zz")
+	if err := req.Validate(); err == nil || !strings.Contains(err.Error(), "invalid query string") {
+		t.Errorf("Expected invalid query string error, got %v", err)
+	}
+	if _, err := req.Execute(); err == nil {
+		t.Errorf("Expected Execute to fail for a malformed query string")
+	}
+
+	req = client.Get("http://example.com")
+	if err := req.SetQueryStringE("a=1&b=2"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if req.URL() != "http://example.com?a=1&b=2" {
+		t.Errorf("Unexpected URL %q", req.URL())
+	}
+}
+
+func TestPathParamEscaping(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+
+	if _, err := client.Get("/files/{name}").SetPathParam("name", "a/b c").Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gotPath != "/files/a%2Fb%20c" {
+		t.Errorf("Expected escaped segment, got %q", gotPath)
+	}
+
+	if _, err := client.Get("/files/{path}").SetRawPathParam("path", "docs/readme.md").Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gotPath != "/files/docs/readme.md" {
+		t.Errorf("Expected raw multi-segment path, got %q", gotPath)
+	}
+
+	// Dot segments must not move the request to another path
+	dotClient := NewClient().SetBaseURL(server.URL+"/v2").SetCommonPathParam("org", ".")
+	if _, err := dotClient.Get("/{org}/users/{id}/profile").SetPathParam("id", "..").Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if gotPath != "/v2/%2E/users/%2E%2E/profile" {
+		t.Errorf("Expected dot segments to stay literal, got %q", gotPath)
+	}
+
+	_, err := client.Get("/users/{id}").SetPathParam("userId", "1").Execute()
+	if !errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("Expected missing path parameter error, got %v", err)
+	}
+}
+
+func TestMissingPathParams(t *testing.T) {
+	client := NewClient().SetCommonPathParam("org", "acme")
+
+	_, err := client.Get("http://example.com/{org}/users/{userId}/posts/{postId}/{userId}").
+		SetPathParam("userid", "1").
+		BuildHTTPRequest()
+	if !errors.Is(err, ErrMissingPathParam) {
+		t.Fatalf("Expected ErrMissingPathParam, got %v", err)
+	}
+	if !strings.HasSuffix(err.Error(), "missing path parameters: userId, postId") {
+		t.Errorf("Expected missing parameter names in error, got %q", err.Error())
+	}
+
+	_, err = client.Get("http://example.com/search?filter={\"a\":1}").BuildHTTPRequest()
+	if errors.Is(err, ErrMissingPathParam) {
+		t.Errorf("Expected JSON braces not to be treated as placeholders")
+	}
+}
+
+func TestBaseURLJoining(t *testing.T) {
+	tests := []struct {
+		base     string
+		path     string
+		expected string
+	}{
+		{"https://api.example.com/v2", "/users", "https://api.example.com/v2/users"},
+		{"https://api.example.com/v2/", "users", "https://api.example.com/v2/users"},
+		{"https://api.example.com/v2", "./users", "https://api.example.com/v2/users"},
+		{"https://api.example.com/v2/admin", "../users", "https://api.example.com/v2/users"},
+		{"https://api.example.com/v2", "https://other.example.com/x", "https://other.example.com/x"},
+		{"https://api.example.com/v2", "httpbin/status", "https://api.example.com/v2/httpbin/status"},
+		{"https://api.example.com/v2", "users?active=true", "https://api.example.com/v2/users?active=true"},
+	}
+
+	for _, tt := range tests {
+		req := NewClient().SetBaseURL(tt.base).Get(tt.path)
+		if got := req.URL(); got != tt.expected {
+			t.Errorf("base %q + %q: expected %q, got %q", tt.base, tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestResponseHeadHelpers(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1234")
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Header().Set("ETag", `"abc"`)
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().Head(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.ContentLength() != 1234 {
+		t.Errorf("Expected content length 1234, got %d", resp.ContentLength())
+	}
+	if lm, err := resp.LastModified(); err != nil || !lm.Equal(modified) {
+		t.Errorf("Expected last modified %v, got %v (err=%v)", modified, lm, err)
+	}
+	if resp.ETag() != `"abc"` {
+		t.Errorf("Expected ETag \"abc\", got %q", resp.ETag())
+	}
+}
+
+func TestResponseAttemptHistory(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().
+		SetRetryCount(3).
+		SetRetryInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return err != nil || resp.StatusCode >= 500
+		}).
+		Get(server.URL).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.Attempts() != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", resp.Attempts())
+	}
+	history := resp.AttemptHistory()
+	if history[0].StatusCode != http.StatusBadGateway || history[1].StatusCode != http.StatusBadGateway || history[2].StatusCode != http.StatusOK {
+		t.Errorf("Unexpected attempt history: %+v", history)
+	}
+}
+
+func TestSetIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewClient().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool {
+			return resp.StatusCode == http.StatusServiceUnavailable
+		}).
+		Post(server.URL).
+		SetIdempotencyKey("").
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(keys) != 3 || len(keys[0]) != 36 {
+		t.Fatalf("Expected 3 attempts with a generated UUID, got %q", keys)
+	}
+	if keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("Expected the same key on every attempt, got %q", keys)
+	}
+
+	req := NewClient().Post(server.URL).SetIdempotencyKey("order-42")
+	if req.Header().Get("Idempotency-Key") != "order-42" {
+		t.Errorf("Expected explicit key to be used")
+	}
+}
+
+func TestConditionalRequestHelpers(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("WIB", 7*3600))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write([]byte("fresh"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Get(server.URL).SetIfNoneMatch("v1").Execute()
+	if err != nil || !resp.IsNotModified() {
+		t.Fatalf("Expected 304 Not Modified, got %v (err=%v)", resp, err)
+	}
+
+	req := client.Put(server.URL).
+		SetIfMatch(`W/"v2"`).
+		SetIfModifiedSince(modified).
+		SetIfUnmodifiedSince(modified)
+	if got := req.Header().Get("If-Match"); got != `W/"v2"` {
+		t.Errorf("Expected weak etag unchanged, got %q", got)
+	}
+	if got := req.Header().Get("If-Modified-Since"); got != "Mon, 01 Jan 2024 20:04:05 GMT" {
+		t.Errorf("Unexpected If-Modified-Since %q", got)
+	}
+	if req.Header().Get("If-Unmodified-Since") != req.Header().Get("If-Modified-Since") {
+		t.Errorf("Expected If-Unmodified-Since to use the same HTTP date format")
+	}
+}
+
+func TestMaxDecompressedSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(bytes.Repeat([]byte("a"), 1<<20))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	_, err := NewClient().SetMaxDecompressedSize(1024).Get(server.URL).Execute()
+	if !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge for a decompression bomb, got %v", err)
+	}
+
+	resp, err := NewClient().SetMaxDecompressedSize(2 << 20).Get(server.URL).Execute()
+	if err != nil || resp.Size() != 1<<20 {
+		t.Errorf("Expected full decompressed body within the limit, got size %d (err=%v)", resp.Size(), err)
+	}
+}
+
+func TestRequestTemplate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s %s", r.URL.Path, r.Header.Get("X-Team"), r.URL.Query().Get("page"), r.UserAgent())
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+	template := client.NewTemplate().
+		SetHeader("X-Team", "core").
+		SetQueryParam("page", "1").
+		SetUserAgent("cumi-template")
+
+	users, err := template.Clone().SetQueryParam("page", "2").Get("/users")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if users.String() != "/users core 2 cumi-template" {
+		t.Errorf("Unexpected response %q", users.String())
+	}
+
+	posts, err := template.Clone().Get("/posts")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if posts.String() != "/posts core 1 cumi-template" {
+		t.Errorf("Expected template to be unchanged by earlier clones, got %q", posts.String())
+	}
+}
+
+type cloneContextKey struct{}
+
+func TestRequestCloneCopiesAllFields(t *testing.T) {
+	ctx := context.WithValue(context.Background(), cloneContextKey{}, "value")
+	tracer := noop.NewTracerProvider().Tracer("test")
+
+	original := NewClient().Post("http://example.com/{id}").
+		SetContext(ctx).
+		SetUserAgent("cumi-test").
+		SetTracer(tracer, "span").
+		SetHeader("X-Test", "1").
+		SetQueryParam("q", "1").
+		SetPathParam("id", "1").
+		SetFormData(map[string]string{"a": "b"}).
+		SetBodyJSON(map[string]string{"a": "b"}).
+		SetCookie(&http.Cookie{Name: "c", Value: "v"}).
+		SetBasicAuth("user", "pass").
+		SetBearerToken("token").
+		SetSuccessResult(&struct{}{}).
+		SetErrorResult(&struct{}{}).
+		SetOutputResume(filepath.Join(t.TempDir(), "out")).
+		SetUploadCallback(func(int64, int64) {}).
+		SetRetryCount(1).
+		SetRetryInterval(time.Second).
+		SetRetryCondition(func(*Response, error) bool { return false }).
+		SetAWSSigV4("key", "secret", "", "us-east-1", "s3").
+		WithTimeout(time.Second).
+		WithDeadline(time.Now().Add(time.Minute)).
+		SetTransport(http.DefaultTransport).
+		SetQueryString("%zz")
+
+	clone := original.Clone()
+	if clone.Context() != ctx || clone.Context().Value(cloneContextKey{}) != "value" {
+		t.Errorf("Expected clone to keep the original context")
+	}
+	if clone.userAgent != "cumi-test" || clone.tracer != tracer || clone.spanName != "span" {
+		t.Errorf("Expected clone to keep userAgent and tracing configuration")
+	}
+
+	ov, cv := reflect.ValueOf(original).Elem(), reflect.ValueOf(clone).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		if !ov.Field(i).IsZero() && cv.Field(i).IsZero() {
+			t.Errorf("Request.Clone dropped field %s", ov.Type().Field(i).Name)
+		}
+	}
+}
+
+func TestResponseDecodeStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[{"name":"John","age":30},{"name":"Jane","age":25}]`))
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().Get(server.URL).
+		EnableStreamResponse().
+		WithTimeout(5 * time.Second).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(resp.Body()) != 0 {
+		t.Errorf("Expected body not to be buffered in stream mode")
+	}
+
+	var users []User
+	err = resp.DecodeStream(func(decoder *json.Decoder) error {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var user User
+			if err := decoder.Decode(&user); err != nil {
+				return err
+			}
+			users = append(users, user)
+		}
+		_, err := decoder.Token()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Expected no decode error, got %v", err)
+	}
+	if len(users) != 2 || users[1].Name != "Jane" {
+		t.Errorf("Unexpected users %+v", users)
+	}
+}
+
+func TestRequestSetBaseURL(t *testing.T) {
+	client := NewClient().SetBaseURL("https://api.example.com/v1")
+
+	req := client.Get("/health").SetBaseURL("https://status.example.com/")
+	if req.URL() != "https://status.example.com/health" {
+		t.Errorf("Expected request base URL to be used, got %q", req.URL())
+	}
+	if got := client.Get("/health").URL(); got != "https://api.example.com/v1/health" {
+		t.Errorf("Expected client base URL to be unchanged, got %q", got)
+	}
+}
+
+func TestSetUseJSONNumber(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":9007199254740993}`))
+	}))
+	defer server.Close()
+
+	var result map[string]interface{}
+	_, err := NewClient().SetUseJSONNumber(true).Get(server.URL).SetSuccessResult(&result).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	id, ok := result["id"].(json.Number)
+	if !ok || id.String() != "9007199254740993" {
+		t.Errorf("Expected id as exact json.Number, got %#v", result["id"])
+	}
+}
+
+func TestJSONDisallowUnknownFields(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"John","age":30,"email":"john@example.com"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetJSONDisallowUnknownFields(true)
+
+	var user User
+	resp, err := client.Get(server.URL).SetSuccessResult(&user).Execute()
+	if err == nil || !strings.Contains(err.Error(), `unknown field "email"`) {
+		t.Errorf("Expected unknown field error from result binding, got %v", err)
+	}
+	if err := resp.JSON(&user); err == nil {
+		t.Errorf("Expected unknown field error from Response.JSON")
+	}
+
+	resp, err = client.Get(server.URL).SetJSONDisallowUnknownFields(false).SetSuccessResult(&user).Execute()
+	if err != nil || user.Name != "John" {
+		t.Errorf("Expected per-request override to allow unknown fields, got %v", err)
+	}
+	if err := resp.JSON(&user); err != nil {
+		t.Errorf("Expected Response.JSON to honor the request override, got %v", err)
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	client := NewClient().SetBaseURL("https://api.example.com")
+
+	a := client.Post("/users").
+		SetQueryParam("a", "1").
+		SetQueryParam("b", "2").
+		SetHeader("X-One", "1").
+		SetHeader("X-Two", "2").
+		SetBodyJSON(map[string]string{"name": "John"})
+	b := client.Post("/users").
+		SetQueryParam("b", "2").
+		SetQueryParam("a", "1").
+		SetHeader("X-Two", "2").
+		SetHeader("X-One", "1").
+		SetBodyJSON(map[string]string{"name": "John"})
+
+	if a.Fingerprint() == "" || a.Fingerprint() != b.Fingerprint() {
+		t.Errorf("Expected equal fingerprints regardless of insertion order, got %q and %q", a.Fingerprint(), b.Fingerprint())
+	}
+
+	c := b.Clone().SetBodyJSON(map[string]string{"name": "Jane"})
+	if c.Fingerprint() == a.Fingerprint() {
+		t.Errorf("Expected different body to change the fingerprint")
+	}
+
+	signed := a.Clone().SetAWSSigV4("key", "secret", "", "us-east-1", "execute-api")
+	first := signed.Fingerprint()
+	sigV4Now = func() time.Time { return time.Now().Add(time.Hour) }
+	defer func() { sigV4Now = time.Now }()
+	if signed.Fingerprint() != first {
+		t.Errorf("Expected signing time not to affect the fingerprint")
+	}
+
+	var tokenCalls int
+	client.SetBearerTokenFunc(func(ctx context.Context) (string, error) {
+		tokenCalls++
+		return "token", nil
+	})
+	if a.Fingerprint(); tokenCalls != 0 {
+		t.Errorf("Expected the token func not to be called, got %d calls", tokenCalls)
+	}
+	if a.Clone().SetBearerToken("one").Fingerprint() == a.Clone().SetBearerToken("two").Fingerprint() {
+		t.Errorf("Expected different credentials to change the fingerprint")
+	}
+}
+
+func TestSingleFlight(t *testing.T) {
+	var hits atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"John","age":30}`))
+	}))
+	defer server.Close()
+
+	client := NewClient().EnableSingleFlight()
+
+	// A caller whose context is cancelled must not cancel the shared call
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		_, err := client.Get(server.URL).SetContext(ctx).Execute()
+		cancelled <- err
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected cancelled caller to return context.Canceled, got %v", err)
+	}
+
+	const callers = 5
+	users := make([]User, callers)
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func(i int) {
+			_, err := client.Get(server.URL).SetSuccessResult(&users[i]).Execute()
+			errs <- err
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < callers; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("Expected a single request to reach the server, got %d", hits.Load())
+	}
+	for i, user := range users {
+		if user.Name != "John" {
+			t.Errorf("Expected caller %d to get its own bound result, got %+v", i, user)
+		}
+	}
+
+	// Requests writing to a writer each receive the body
+	var writerHits atomic.Int32
+	writerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hold the response until both requests arrive, so coalescing would show
+		writerHits.Add(1)
+		for deadline := time.Now().Add(time.Second); writerHits.Load() < 2 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		w.Write([]byte(`{"name":"John"}`))
+	}))
+	defer writerServer.Close()
+	var buffers [2]bytes.Buffer
+	var wg sync.WaitGroup
+	for i := range buffers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := client.Get(writerServer.URL).SetWriter(&buffers[i]).Execute(); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if writerHits.Load() != 2 {
+		t.Errorf("Expected writer requests not to be coalesced, got %d server hits", writerHits.Load())
+	}
+	for i := range buffers {
+		if !strings.Contains(buffers[i].String(), "John") {
+			t.Errorf("Expected writer %d to receive the body, got %q", i, buffers[i].String())
+		}
+	}
+}
+
+func TestClientCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+	if _, err := client.Post("/login").Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	cookies := client.Cookies("/profile")
+	if len(cookies) != 1 || cookies[0].Name != "session" || cookies[0].Value != "abc123" {
+		t.Fatalf("Expected session cookie in jar, got %v", cookies)
+	}
+
+	client.ClearCookies()
+	if cookies := client.Cookies(server.URL); len(cookies) != 0 {
+		t.Errorf("Expected no cookies after ClearCookies, got %v", cookies)
+	}
+
+	// Jar options survive clearing, and other custom jars are kept
+	options := &cookiejar.Options{PublicSuffixList: testSuffixList{}}
+	client.SetCookieJar(NewCookieJar(options)).ClearCookies()
+	if jar, ok := client.GetCookieJar().(*cookieJar); !ok || jar.options != options {
+		t.Errorf("Expected ClearCookies to keep the jar options")
+	}
+	custom, _ := cookiejar.New(options)
+	if client.SetCookieJar(custom).ClearCookies().GetCookieJar() != custom {
+		t.Errorf("Expected ClearCookies to keep a custom jar")
+	}
+}
+
+// testSuffixList treats every top-level domain as a public suffix
+type testSuffixList struct{}
+
+func (testSuffixList) PublicSuffix(domain string) string {
+	return domain[strings.LastIndex(domain, ".")+1:]
+}
+
+func (testSuffixList) String() string { return "test" }
+
+func TestClientJSONHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var user User
+		json.NewDecoder(r.Body).Decode(&user)
+		if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+			user = User{Name: r.Method}
+		} else if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user.Age++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(user)
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBaseURL(server.URL)
+
+	var got User
+	if _, err := client.GetJSON("/users/1", &got); err != nil || got.Name != "GET" {
+		t.Errorf("GetJSON: unexpected result %+v (err=%v)", got, err)
+	}
+	for name, call := range map[string]func(string, interface{}, interface{}) (*Response, error){
+		"PostJSON":  client.PostJSON,
+		"PutJSON":   client.PutJSON,
+		"PatchJSON": client.PatchJSON,
+	} {
+		got = User{}
+		if _, err := call("/users", User{Name: "John", Age: 30}, &got); err != nil || got.Name != "John" || got.Age != 31 {
+			t.Errorf("%s: unexpected result %+v (err=%v)", name, got, err)
+		}
+	}
+	got = User{}
+	if _, err := client.DeleteJSON("/users/1", nil, &got); err != nil || got.Name != "DELETE" {
+		t.Errorf("DeleteJSON: unexpected result %+v (err=%v)", got, err)
+	}
+}
+
+func TestAllowGetPayload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s|%s", body, r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	resp, err := client.Get(server.URL).SetBodyJSON(map[string]string{"q": "cumi"}).Execute()
+	if err != nil || resp.String() != "|" {
+		t.Errorf("Expected GET body to be dropped by default, got %q (err=%v)", resp.String(), err)
+	}
+
+	client.EnableAllowGetMethodPayload()
+	resp, err = client.Get(server.URL).SetBodyJSON(map[string]string{"q": "cumi"}).Execute()
+	if err != nil || resp.String() != `{"q":"cumi"}|application/json` {
+		t.Errorf("Expected GET body to be sent when allowed, got %q (err=%v)", resp.String(), err)
+	}
+}
+
+func TestSetBodyReaderWithSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%d|%v|%d", r.ContentLength, r.TransferEncoding, len(body))
+	}))
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte("x"), 64*1024)
+	var lastWritten, lastTotal int64
+	progress := func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	}
+
+	resp, err := NewClient().Post(server.URL).
+		SetBodyReaderWithSize(io.MultiReader(bytes.NewReader(payload)), int64(len(payload))).
+		SetUploadCallback(progress).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "65536|[]|65536" {
+		t.Errorf("Expected Content-Length upload, got %q", resp.String())
+	}
+	if lastWritten != int64(len(payload)) || lastTotal != int64(len(payload)) {
+		t.Errorf("Expected progress %d/%d, got %d/%d", len(payload), len(payload), lastWritten, lastTotal)
+	}
+
+	resp, err = NewClient().Post(server.URL).
+		SetBodyReader(io.MultiReader(bytes.NewReader(payload))).
+		SetUploadCallback(progress).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "-1|[chunked]|65536" || lastTotal != -1 {
+		t.Errorf("Expected chunked upload with unknown total, got %q (total=%d)", resp.String(), lastTotal)
+	}
+}
+
+func TestRetryableStatusCodes(t *testing.T) {
+	var calls int
+	status := http.StatusRequestTimeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		SetRetryableStatusCodes(http.StatusRequestTimeout).
+		SetNonRetryableStatusCodes(http.StatusServiceUnavailable)
+
+	client.Get(server.URL).Execute()
+	if calls != 3 {
+		t.Errorf("Expected 408 to be retried, got %d calls", calls)
+	}
+
+	calls, status = 0, http.StatusServiceUnavailable
+	client.Get(server.URL).Execute()
+	if calls != 1 {
+		t.Errorf("Expected 503 not to be retried, got %d calls", calls)
+	}
+
+	calls, status = 0, http.StatusBadGateway
+	client.Get(server.URL).Execute()
+	if calls != 3 {
+		t.Errorf("Expected other 5xx to keep the default retry behavior, got %d calls", calls)
+	}
+}
+
+func TestMaxRetryElapsedTime(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := NewClient().
+		SetRetryCount(100).
+		SetRetryInterval(20 * time.Millisecond).
+		SetMaxRetryElapsedTime(70 * time.Millisecond).
+		Get(server.URL).
+		Execute()
+	elapsed := time.Since(start)
+
+	if err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the last 503 response, got %v (err=%v)", resp, err)
+	}
+	if calls < 2 || calls > 4 {
+		t.Errorf("Expected retries to stop within the budget, got %d calls", calls)
+	}
+	if elapsed > time.Second {
+		t.Errorf("Expected retry budget to bound elapsed time, took %v", elapsed)
+	}
+}
+
+func TestSetHostConfig(t *testing.T) {
+	var failCalls atomic.Int32
+	slowDone := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+			slowDone <- struct{}{}
+		}
+		if r.URL.Path == "/fail" {
+			failCalls.Add(1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "%s|%s", r.Header.Get("X-Service"), r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	retries := 2
+	interval := time.Millisecond
+	client := NewClient().SetHostConfig(u.Hostname(), HostConfig{
+		Headers:       map[string]string{"X-Service": "billing"},
+		BearerToken:   "host-token",
+		Timeout:       20 * time.Millisecond,
+		RetryCount:    &retries,
+		RetryInterval: &interval,
+	})
+
+	resp, err := client.Get(server.URL).Execute()
+	if err != nil || resp.String() != "billing|Bearer host-token" {
+		t.Errorf("Expected host headers and auth, got %q (err=%v)", resp.String(), err)
+	}
+
+	resp, err = client.Get(server.URL).SetBearerToken("request-token").SetHeader("X-Service", "orders").Execute()
+	if err != nil || resp.String() != "orders|Bearer request-token" {
+		t.Errorf("Expected request settings to win over host config, got %q (err=%v)", resp.String(), err)
+	}
+
+	if _, err := client.Get(server.URL + "/slow").SetRetryCount(0).Execute(); err == nil {
+		t.Errorf("Expected host timeout to apply")
+	}
+	// The handler keeps running after the client gives up
+	<-slowDone
+
+	client.Get(server.URL + "/fail").Execute()
+	if calls := failCalls.Load(); calls != 3 {
+		t.Errorf("Expected host retry count to apply, got %d calls", calls)
+	}
+
+	resp, err = client.Get("http://localhost:" + u.Port()).Execute()
+	if err != nil || resp.String() != "|" {
+		t.Errorf("Expected other hosts to be unaffected, got %q (err=%v)", resp.String(), err)
+	}
+}
+
+func TestResponseByteCounters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(bytes.Repeat([]byte("y"), 2048))
+	}))
+	defer server.Close()
+
+	payload := bytes.Repeat([]byte("x"), 4096)
+	resp, err := NewClient().Post(server.URL).SetBodyBytes(payload).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if resp.BytesSent() <= int64(len(payload)) || resp.BytesSent() > int64(len(payload))+512 {
+		t.Errorf("Expected bytes sent to be the body plus headers, got %d", resp.BytesSent())
+	}
+	if resp.BytesReceived() <= resp.Size() || resp.BytesReceived() > resp.Size()+512 {
+		t.Errorf("Expected bytes received to be the body plus headers, got %d (body %d)", resp.BytesReceived(), resp.Size())
+	}
+
+	resp, err = NewClient().Get(server.URL).EnableStreamResponse().Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	headersOnly := resp.BytesReceived()
+	io.Copy(io.Discard, resp.Raw())
+	resp.Close()
+	if resp.BytesReceived() != headersOnly+2048 {
+		t.Errorf("Expected streamed body to be counted as read, got %d (headers %d)", resp.BytesReceived(), headersOnly)
+	}
+}
+
+func TestSetBodyMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			data, _ := io.ReadAll(part)
+			fmt.Fprintf(w, "%s:%s:%s:%d;", part.FormName(), part.FileName(), part.Header.Get("Content-Type"), len(data))
+		}
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().Post(server.URL).
+		SetBodyMultipart(func(w *multipart.Writer) error {
+			if err := w.WriteField("title", "report"); err != nil {
+				return err
+			}
+			header := make(textproto.MIMEHeader)
+			header.Set("Content-Disposition", `form-data; name="file"; filename="data.csv"`)
+			header.Set("Content-Type", "text/csv")
+			part, err := w.CreatePart(header)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(part, io.LimitReader(zeroReader{}, 1<<20))
+			return err
+		}).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "title:::6;file:data.csv:text/csv:1048576;" {
+		t.Errorf("Unexpected parts %q", resp.String())
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
+
+func TestDialAndTLSHandshakeTimeout(t *testing.T) {
+	client := NewClient().
+		SetDialTimeout(50 * time.Millisecond).
+		SetTLSHandshakeTimeout(75 * time.Millisecond)
+
+	transport := client.GetTransport().(*http.Transport)
+	if transport.TLSHandshakeTimeout != 75*time.Millisecond {
+		t.Errorf("Expected TLS handshake timeout to be set, got %v", transport.TLSHandshakeTimeout)
+	}
+
+	// A non-routable address makes the connect hang until the dial timeout
+	start := time.Now()
+	_, err := client.Clone().SetTimeout(5 * time.Second).Get("http://10.255.255.1:81").Execute()
+	if err == nil {
+		t.Fatalf("Expected dial error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected dial timeout to fail fast, took %v", elapsed)
+	}
+}
+
+func TestExpectContinue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Expect") == "100-continue" {
+			// Reject without reading the body, so no 100 Continue is sent
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		SetExpectContinueTimeout(5 * time.Second).
+		SetExpectContinueThreshold(1024)
+
+	var uploaded int64
+	resp, err := client.Post(server.URL).
+		SetBodyBytes(bytes.Repeat([]byte("x"), 1<<20)).
+		SetUploadCallback(func(written, total int64) { uploaded = written }).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 rejection, got %d", resp.StatusCode)
+	}
+	if uploaded != 0 {
+		t.Errorf("Expected no upload progress before 100 Continue, got %d bytes", uploaded)
+	}
+
+	resp, err = client.Post(server.URL).SetBodyString("small").Execute()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected small bodies to skip Expect, got %v (err=%v)", resp, err)
+	}
+}
+
+func TestResponseNDJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"name\":\"John\",\"age\":30}\r\n\n{\"name\":\"Jane\",\"age\":25}"))
+	}))
+	defer server.Close()
+
+	for _, stream := range []bool{false, true} {
+		req := NewClient().Get(server.URL)
+		if stream {
+			req.EnableStreamResponse()
+		}
+		resp, err := req.Execute()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+
+		var users []User
+		err = resp.NDJSON(func(line json.RawMessage) error {
+			var user User
+			if err := json.Unmarshal(line, &user); err != nil {
+				return err
+			}
+			users = append(users, user)
+			return nil
+		})
+		if err != nil || len(users) != 2 || users[0].Name != "John" || users[1].Age != 25 {
+			t.Errorf("stream=%v: unexpected records %+v (err=%v)", stream, users, err)
+		}
+	}
+}
+
+func TestSetBaseURLValidation(t *testing.T) {
+	client := NewClient()
+	if err := client.SetBaseURLE("HTTPS://API.Example.COM/V1/"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := client.Get("/users").URL(); got != "https://api.example.com/V1/users" {
+		t.Errorf("Expected normalized base URL, got %q", got)
+	}
+
+	if err := client.SetBaseURLE("api.example.com"); err == nil {
+		t.Errorf("Expected error for base URL without scheme")
+	}
+	if err := client.SetBaseURLE("https://{region}.example.com/{version}"); err != nil {
+		t.Errorf("Expected placeholders to be accepted, got %v", err)
+	}
+
+	_, err := NewClient().SetBaseURL("://bad").Get("/users").Execute()
+	if err == nil || !strings.Contains(err.Error(), "invalid base URL") {
+		t.Errorf("Expected stored base URL error, got %v", err)
+	}
+
+	_, err = NewClient().Get("/users").SetBaseURL("/relative").Execute()
+	if err == nil || !strings.Contains(err.Error(), "missing scheme or host") {
+		t.Errorf("Expected request base URL error, got %v", err)
+	}
+
+	// Setting a valid value again clears the error, on clones too
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	bad := NewClient().SetBaseURL("://bad").SetRootCAs([]byte("not a certificate"))
+	clone := bad.Clone().SetBaseURL(server.URL)
+	if _, err := clone.Get("/users").Execute(); err == nil || !strings.Contains(err.Error(), "root CA") {
+		t.Errorf("Expected the root CA error to remain, got %v", err)
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if _, err := clone.SetRootCAs(caPEM).Get("/users").Execute(); err != nil {
+		t.Errorf("Expected errors to clear after valid settings, got %v", err)
+	}
+	if _, err := bad.Get("/users").Execute(); err == nil || !strings.Contains(err.Error(), "invalid base URL") {
+		t.Errorf("Expected the parent to keep its base URL error, got %v", err)
+	}
+}
+
+func TestSetFormDataFromStruct(t *testing.T) {
+	type address struct {
+		City string `form:"city"`
+		Zip  string `form:"zip,omitempty"`
+	}
+	type item struct {
+		ID int `form:"id"`
+	}
+	type Audit struct {
+		Source string `form:"source"`
+	}
+	type order struct {
+		*Audit
+		Name     string         `form:"name"`
+		Tags     []string       `form:"tags"`
+		Address  address        `form:"address"`
+		Items    []item         `form:"items"`
+		Note     string         `form:"note,omitempty"`
+		Created  time.Time      `form:"created" layout:"2006-01-02"`
+		Expires  time.Time      `form:"expires,unix"`
+		Internal string         `form:"-"`
+		Quantity *int           `form:"quantity"`
+		Meta     map[string]int `form:"meta"`
+	}
+
+	var got url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r.PostForm
+	}))
+	defer server.Close()
+
+	_, err := NewClientWithConfig(&Config{}).Post(server.URL).SetFormDataFromStruct(&order{
+		Name:     "book",
+		Tags:     []string{"a", "b"},
+		Address:  address{City: "Jakarta"},
+		Items:    []item{{ID: 1}, {ID: 2}},
+		Created:  time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		Expires:  time.Unix(1700000000, 0),
+		Internal: "secret",
+		Meta:     map[string]int{"priority": 1},
+		Audit:    &Audit{Source: "web"},
+	}).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := url.Values{
+		"name":           {"book"},
+		"tags[]":         {"a", "b"},
+		"address[city]":  {"Jakarta"},
+		"items[0][id]":   {"1"},
+		"items[1][id]":   {"2"},
+		"created":        {"2024-05-06"},
+		"expires":        {"1700000000"},
+		"meta[priority]": {"1"},
+		"source":         {"web"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected form %v, got %v", expected, got)
+	}
+
+	_, err = NewClient().Post(server.URL).SetFormDataFromStruct("not a struct").Execute()
+	if err == nil {
+		t.Errorf("Expected error for non-struct form data")
+	}
+}
+
+func TestSetWriter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"name":"not found"}`))
+			return
+		}
+		w.Write([]byte("streamed content"))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	resp, err := NewClient().Get(server.URL).SetWriter(&buf).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if buf.String() != "streamed content" {
+		t.Errorf("Expected body in writer, got %q", buf.String())
+	}
+	if len(resp.Body()) != 0 || resp.Size() != int64(len("streamed content")) {
+		t.Errorf("Expected empty buffered body and size %d, got %q and %d", len("streamed content"), resp.Body(), resp.Size())
+	}
+
+	buf.Reset()
+	var errResult User
+	resp, err = NewClient().Get(server.URL + "/missing").SetWriter(&buf).SetErrorResult(&errResult).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if buf.Len() != 0 || errResult.Name != "not found" || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected error response to be buffered, got writer %q and result %+v", buf.String(), errResult)
+	}
+}
+
+func TestConnectionReuseOptions(t *testing.T) {
+	var reused []bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetIdleConnTimeout(5 * time.Second).DisableKeepAlives()
+	transport := client.GetTransport().(*http.Transport)
+	if transport.IdleConnTimeout != 5*time.Second || !transport.DisableKeepAlives {
+		t.Fatalf("Expected transport to be configured, got idle=%v keepAlives disabled=%v", transport.IdleConnTimeout, transport.DisableKeepAlives)
+	}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL).Execute()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		reused = append(reused, resp.TraceInfo().IsConnReused)
+	}
+	if reused[1] {
+		t.Errorf("Expected a fresh connection with keep-alives disabled")
+	}
+
+	if client.EnableKeepAlives(); transport.DisableKeepAlives {
+		t.Errorf("Expected keep-alives to be re-enabled")
+	}
+}
+
+func TestBatch(t *testing.T) {
+	var running, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte(r.URL.Query().Get("id")))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetBatchConcurrency(2)
+	var requests []*Request
+	for i := 0; i < 6; i++ {
+		requests = append(requests, client.Get(server.URL).SetQueryParam("id", strconv.Itoa(i)))
+	}
+
+	results := client.Batch(requests...)
+	for i, result := range results {
+		if result.Err != nil {
+			t.Fatalf("request %d: expected no error, got %v", i, result.Err)
+		}
+		if result.Response.String() != strconv.Itoa(i) {
+			t.Errorf("request %d: expected ordered result, got %q", i, result.Response.String())
+		}
+	}
+	if peak.Load() > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", peak.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = client.BatchContext(ctx, requests...)
+	for i, result := range results {
+		if !errors.Is(result.Err, context.Canceled) {
+			t.Errorf("request %d: expected context.Canceled, got %v", i, result.Err)
+		}
+	}
+}
+
+func TestResponseTrailers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte("payload"))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer server.Close()
+
+	resp, err := NewClient().Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := resp.Trailers().Get("Grpc-Status"); got != "0" {
+		t.Errorf("Expected trailer Grpc-Status 0, got %q", got)
+	}
+
+	resp, err = NewClient().Get(server.URL).EnableStreamResponse().Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	io.ReadAll(resp.Raw())
+	resp.Close()
+	if got := resp.Trailers().Get("Grpc-Status"); got != "0" {
+		t.Errorf("Expected streamed trailer Grpc-Status 0, got %q", got)
+	}
+}
+
+func TestPatchBodies(t *testing.T) {
+	var contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	client := NewClientWithConfig(&Config{})
+	_, err := client.Patch(server.URL).SetBodyJSONPatch([]PatchOp{
+		{Op: "replace", Path: "/name", Value: "Jane"},
+		{Op: "add", Path: "/nickname"},
+		{Op: "remove", Path: "/age"},
+		{Op: "move", From: "/a", Path: "/b"},
+	}).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := `[{"op":"replace","path":"/name","value":"Jane"},{"op":"add","path":"/nickname","value":null},{"op":"remove","path":"/age"},{"op":"move","path":"/b","from":"/a"}]`
+	if contentType != "application/json-patch+json" || body != expected {
+		t.Errorf("Unexpected JSON Patch request: %s %s", contentType, body)
+	}
+
+	_, err = client.Patch(server.URL).SetBodyMergePatch(map[string]interface{}{"age": nil}).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if contentType != "application/merge-patch+json" || body != `{"age":null}` {
+		t.Errorf("Unexpected merge patch request: %s %s", contentType, body)
+	}
+}
+
+func TestDebugBodyLimit(t *testing.T) {
+	payload := strings.Repeat("a", 400) + "END"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	if _, err := NewClient().EnableDebug().Get(server.URL).Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(buf.String(), "END") || !strings.Contains(buf.String(), "...(truncated)") {
+		t.Errorf("Expected body truncated at the default limit")
+	}
+
+	buf.Reset()
+	if _, err := NewClient().EnableDebug().SetDebugBodyLimit(0).Get(server.URL).Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(buf.String(), payload) {
+		t.Errorf("Expected full body with an unlimited debug body limit")
+	}
+}
+
+func TestContentSniffing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if r.URL.Path == "/xml" {
+			w.Write([]byte(`<?xml version="1.0"?><user><Name>John</Name></user>`))
+			return
+		}
+		w.Write([]byte(`{"name":"John","age":30}`))
+	}))
+	defer server.Close()
+
+	var user User
+	resp, err := NewClient().Get(server.URL).SetResult(&user).Execute()
+	if err == nil {
+		t.Fatalf("Expected decoding error without sniffing")
+	}
+	if resp.IsJSON() || resp.DetectContentType() != "application/json" {
+		t.Errorf("Expected header-based IsJSON and sniffed application/json, got %v %q", resp.IsJSON(), resp.DetectContentType())
+	}
+
+	client := NewClient().EnableContentSniffing()
+	resp, err = client.Get(server.URL).SetResult(&user).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.IsJSON() || user.Name != "John" {
+		t.Errorf("Expected sniffed JSON result, got IsJSON=%v user=%+v", resp.IsJSON(), user)
+	}
+
+	resp, err = client.Get(server.URL + "/xml").Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !resp.IsXML() {
+		t.Errorf("Expected sniffed XML, got %q", resp.DetectContentType())
+	}
+}
+
+func TestSetBearerTokenFunc(t *testing.T) {
+	var calls atomic.Int32
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+		switch r.Header.Get("Authorization") {
+		case "Bearer token-1":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "Bearer token-2":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().
+		SetRetryCount(2).
+		SetRetryInterval(time.Millisecond).
+		SetRetryCondition(func(resp *Response, err error) bool { return resp != nil && resp.StatusCode >= 400 }).
+		SetBearerTokenFunc(func(ctx context.Context) (string, error) {
+			return fmt.Sprintf("token-%d", calls.Add(1)), nil
+		})
+
+	if _, err := client.Get(server.URL).Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"Bearer token-1", "Bearer token-2", "Bearer token-2"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected tokens %v, got %v", expected, seen)
+	}
+
+	seen = nil
+	client.Get(server.URL).SetBearerToken("static").Execute()
+	if calls.Load() != 2 || seen[0] != "Bearer static" {
+		t.Errorf("Expected request token to take precedence, got %v after %d calls", seen, calls.Load())
+	}
+
+	failing := NewClient().SetBearerTokenFunc(func(ctx context.Context) (string, error) {
+		return "", errors.New("token source down")
+	})
+	_, err := failing.Get(server.URL).Execute()
+	if err == nil || !strings.Contains(err.Error(), "failed to get bearer token: token source down") {
+		t.Errorf("Expected token func error, got %v", err)
+	}
+}
+
+func TestEnableRequestID(t *testing.T) {
+	var ids []string
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get("X-Request-ID"))
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient().EnableRequestID("").SetRetryCount(1).SetRetryInterval(time.Millisecond)
+	resp, err := client.Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(ids) != 2 || ids[0] == "" || ids[0] != ids[1] || resp.RequestID() != ids[0] {
+		t.Errorf("Expected the same generated ID across retries, got %v (resp %q)", ids, resp.RequestID())
+	}
+
+	ids = nil
+	ctx := ContextWithRequestID(context.Background(), "upstream-id")
+	client.Get(server.URL).SetContext(ctx).Execute()
+	client.Get(server.URL).Execute()
+	if ids[0] != "upstream-id" || ids[1] == "upstream-id" || ids[1] == "" {
+		t.Errorf("Expected context ID to be reused only for its request, got %v", ids)
+	}
+}
+
+func TestFingerprintIgnoresRequestID(t *testing.T) {
+	req := NewClient().EnableRequestID("X-Correlation-ID").Get("http://example.com/users")
+	if req.Fingerprint() != req.Fingerprint() {
+		t.Errorf("Expected fingerprint to ignore generated request IDs")
+	}
+}
+
+func TestSetRetryConditionFull(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var attempts []int
+	resp, err := NewClient().
+		SetRetryCount(10).
+		SetRetryInterval(time.Millisecond).
+		SetRetryConditionFull(func(resp *Response, err error, attempt int, elapsed time.Duration) bool {
+			attempts = append(attempts, attempt)
+			return resp.StatusCode >= 500 && attempt < 3 && elapsed < 10*time.Second
+		}).
+		Get(server.URL).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if calls.Load() != 3 || resp.Attempts() != 3 || !reflect.DeepEqual(attempts, []int{1, 2, 3}) {
+		t.Errorf("Expected 3 attempts, got %d calls and condition attempts %v", calls.Load(), attempts)
+	}
+}
+
+// providerTransport wraps an *http.Transport like the http3 module's transport
+type providerTransport struct {
+	base *http.Transport
+}
+
+func (p *providerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return p.base.RoundTrip(req)
+}
+
+func (p *providerTransport) HTTPTransport() *http.Transport {
+	return p.base
+}
+
+func (p *providerTransport) CloneTransport() http.RoundTripper {
+	return &providerTransport{base: p.base.Clone()}
+}
+
+func TestCustomTransportProviderAndCloner(t *testing.T) {
+	wrapper := &providerTransport{base: &http.Transport{}}
+	client := NewClient().SetTransport(wrapper).SetIdleConnTimeout(time.Minute).EnableInsecureSkipVerify()
+	if wrapper.base.IdleConnTimeout != time.Minute || !wrapper.base.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("Expected setters to configure the wrapped transport")
+	}
+
+	cloned, ok := client.Clone().GetTransport().(*providerTransport)
+	if !ok || cloned == wrapper || cloned.base.IdleConnTimeout != time.Minute {
+		t.Errorf("Expected Clone to copy the custom transport, got %#v", cloned)
+	}
+}
+
+func TestWrapTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get("X-Order")))
+	}))
+	defer server.Close()
+
+	var seen []string
+	tag := func(name string) TransportWrapper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				seen = append(seen, name+" "+req.URL.Path)
+				req.Header.Add("X-Order", name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := NewClient().WrapTransport(tag("inner")).WrapTransport(tag("outer")).SetTimeout(5 * time.Second)
+	resp, err := client.Get(server.URL + "/old").Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	expected := []string{"outer /old", "inner /old", "outer /new", "inner /new"}
+	if !reflect.DeepEqual(seen, expected) || resp.String() != "outer" {
+		t.Errorf("Expected wrappers on redirects in order %v, got %v (body %q)", expected, seen, resp.String())
+	}
+	if _, ok := client.GetTransport().(*http.Transport); !ok {
+		t.Errorf("Expected the base transport to stay configurable")
+	}
+
+	seen = nil
+	if _, err := client.Clone().Get(server.URL + "/new").Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected clone to keep wrappers, got %v", seen)
+	}
+}
+
+func TestEnableRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"` + string(body) + `"}`))
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	recorder := NewClient().EnableRecorder(path, RecordModeRecord)
+	for _, name := range []string{"John", "Jane"} {
+		if _, err := recorder.Post(server.URL + "/users").SetBearerToken("secret").SetBody(name).Execute(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+	server.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Contains(string(data), "secret") {
+		t.Fatalf("Expected cassette without credentials, got %s (err=%v)", data, err)
+	}
+
+	replay := NewClient().EnableRecorder(path, RecordModeReplay)
+	var user User
+	resp, err := replay.Post(server.URL + "/users").SetBody("Jane").SetResult(&user).Execute()
+	if err != nil {
+		t.Fatalf("Expected replayed response, got %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || user.Name != "Jane" {
+		t.Errorf("Expected replayed Jane, got %d %+v", resp.StatusCode, user)
+	}
+
+	_, err = replay.Post(server.URL + "/users").SetBody("Bob").Execute()
+	if !errors.Is(err, ErrNoRecordedInteraction) {
+		t.Errorf("Expected ErrNoRecordedInteraction, got %v", err)
+	}
+
+	resp, err = NewClient().
+		EnableRecorder(path, RecordModeReplay).
+		SetRecorderMatcher(func(actual, recorded CassetteRequest) bool { return actual.Method == recorded.Method }).
+		Post(server.URL + "/anything").
+		Execute()
+	if err != nil || resp.String() != `{"name":"John"}` {
+		t.Errorf("Expected custom matcher to match the first interaction, got %v (err=%v)", resp, err)
+	}
+}
+
+func TestRecorderBinaryBodiesAndRedaction(t *testing.T) {
+	binary := []byte{0x1f, 0x8b, 0xff, 0xfe, 0x00, 0x80}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "supersecret"})
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(binary)
+	}))
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	redactor := func(body []byte) []byte {
+		return bytes.ReplaceAll(body, []byte("hunter2"), []byte("***"))
+	}
+
+	recorder := NewClient().SetBodyRedactor(redactor).EnableRecorder(path, RecordModeRecord)
+	resp, err := recorder.Post(server.URL).SetBody("password=hunter2").Execute()
+	if err != nil || !bytes.Equal(resp.Body(), binary) {
+		t.Fatalf("Expected the live binary body, got % x (err=%v)", resp.Body(), err)
+	}
+	server.Close()
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "supersecret") || strings.Contains(string(data), "hunter2") {
+		t.Errorf("Expected redacted cassette, got %s", data)
+	}
+
+	replay := NewClient().SetBodyRedactor(redactor).EnableRecorder(path, RecordModeReplay)
+	resp, err = replay.Post(server.URL).SetBody("password=hunter2").Execute()
+	if err != nil || !bytes.Equal(resp.Body(), binary) {
+		t.Errorf("Expected the binary body to replay unchanged, got % x (err=%v)", resp.Body(), err)
+	}
+}
+
+func TestSetHeaderFromContext(t *testing.T) {
+	type tenantKey struct{}
+	var tenants []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenants = append(tenants, strings.Join(r.Header.Values("X-Tenant-ID"), ","))
+	}))
+	defer server.Close()
+
+	client := NewClient().SetHeaderFromContext("X-Tenant-ID", tenantKey{})
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	client.Get(server.URL).SetContext(ctx).Execute()
+	client.Get(server.URL).Execute()
+	client.Get(server.URL).SetContext(ctx).SetHeader("X-Tenant-ID", "override").Execute()
+
+	expected := []string{"acme", "", "override"}
+	if !reflect.DeepEqual(tenants, expected) {
+		t.Errorf("Expected tenant headers %v, got %v", expected, tenants)
+	}
+}
+
+func TestOnErrorBeforeSend(t *testing.T) {
+	var handled []error
+	client := NewClient().OnError(func(c *Client, req *Request, resp *Response, err error) {
+		if resp != nil {
+			t.Errorf("Expected nil response for an error before sending")
+		}
+		handled = append(handled, err)
+	})
+
+	_, err := client.Post("http://example.com").SetBodyJSON(make(chan int)).Execute()
+	if err == nil || len(handled) != 1 || handled[0] != err {
+		t.Errorf("Expected marshal error to reach OnError, got %v (handled %v)", err, handled)
+	}
+
+	_, err = client.Get("http://example.com/{id}").Execute()
+	if !errors.Is(err, ErrMissingPathParam) || len(handled) != 2 {
+		t.Errorf("Expected URL error to reach OnError, got %v (handled %v)", err, handled)
+	}
+}
+
+func TestEnableHedging(t *testing.T) {
+	var calls atomic.Int32
+	var slowCancelled atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if calls.Add(1) == 1 {
+			select {
+			case <-r.Context().Done():
+				slowCancelled.Store(true)
+				return
+			case <-time.After(2 * time.Second):
+			}
+			w.Write([]byte("slow"))
+			return
+		}
+		w.Write([]byte("fast"))
+	}))
+	defer server.Close()
+
+	client := NewClient().EnableHedging(20*time.Millisecond, 1)
+	start := time.Now()
+	resp, err := client.Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "fast" || time.Since(start) > time.Second {
+		t.Errorf("Expected hedged response, got %q after %v", resp.String(), time.Since(start))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !slowCancelled.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !slowCancelled.Load() {
+		t.Errorf("Expected the losing attempt to be cancelled")
+	}
+
+	calls.Store(10)
+	if _, err := client.Post(server.URL).SetBody("x").Execute(); err != nil || calls.Load() != 11 {
+		t.Errorf("Expected POST not to be hedged, got %d calls (err=%v)", calls.Load()-10, err)
+	}
+
+	// Hedged bodies are counted and report upload progress like the first one
+	calls.Store(0)
+	var completed atomic.Int32
+	resp, err = client.Put(server.URL).
+		SetBody("payload").
+		SetUploadCallback(func(written, total int64) {
+			if written == total {
+				completed.Add(1)
+			}
+		}).
+		Execute()
+	if err != nil || resp.String() != "fast" {
+		t.Fatalf("Expected hedged PUT response, got %v (err=%v)", resp, err)
+	}
+	if completed.Load() != 2 {
+		t.Errorf("Expected upload progress for both bodies, got %d completed", completed.Load())
+	}
+	if sent := resp.BytesSent(); sent < 2*int64(len("payload")) {
+		t.Errorf("Expected both bodies to be counted in BytesSent, got %d", sent)
+	}
+}
+
+func TestResponseBind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"name":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"name":"John","age":30}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	for _, tt := range []struct {
+		path        string
+		wantSuccess string
+		wantError   string
+	}{
+		{"/", "John", ""},
+		{"/missing", "", "not found"},
+	} {
+		resp, err := client.Get(server.URL + tt.path).Execute()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var success, failure User
+		if err := resp.Bind(&success, &failure); err != nil {
+			t.Fatalf("Expected no bind error, got %v", err)
+		}
+		if success.Name != tt.wantSuccess || failure.Name != tt.wantError {
+			t.Errorf("%s: expected success %q and error %q, got %+v and %+v", tt.path, tt.wantSuccess, tt.wantError, success, failure)
+		}
+	}
+}
+
+func TestRequestCodecOverrides(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"John","age":"30"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	var user User
+	_, err := client.Post(server.URL).
+		SetBodyJSON(User{Name: "John"}).
+		SetJSONMarshal(func(v interface{}) ([]byte, error) { return []byte(`{"custom":true}`), nil }).
+		SetJSONUnmarshal(func(data []byte, v interface{}) error {
+			v.(*User).Name = "lenient"
+			return nil
+		}).
+		SetResult(&user).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if received != `{"custom":true}` || user.Name != "lenient" {
+		t.Errorf("Expected request codecs to be used, got body %q and user %+v", received, user)
+	}
+
+	_, err = client.Post(server.URL).SetBodyJSON(User{Name: "John"}).SetResult(&User{}).Execute()
+	if err == nil || !strings.Contains(received, `"name":"John"`) {
+		t.Errorf("Expected client codecs to be untouched, got body %q (err=%v)", received, err)
+	}
+}
+
+func TestTLSSessionResumption(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	resumed := func(client *Client) []bool {
+		var result []bool
+		for i := 0; i < 2; i++ {
+			resp, err := client.Get(server.URL).Execute()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			result = append(result, resp.Response.TLS.DidResume)
+		}
+		return result
+	}
+
+	client := NewClient().EnableInsecureSkipVerify().DisableKeepAlives()
+	if got := resumed(client); got[0] || !got[1] {
+		t.Errorf("Expected the second connection to resume the session, got %v", got)
+	}
+
+	// Clones and certificate changes start with an empty cache
+	clone := client.Clone()
+	if got := resumed(clone); got[0] || !got[1] {
+		t.Errorf("Expected the clone to use its own session cache, got %v", got)
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	clone.SetRootCAs(caPEM)
+	if got := resumed(clone); got[0] {
+		t.Errorf("Expected no resumption after the root CAs changed, got %v", got)
+	}
+	if transport := client.GetTransport().(*http.Transport); transport.TLSClientConfig.RootCAs != nil {
+		t.Errorf("Expected the clone's root CAs not to leak into the parent")
+	}
+	if got := resumed(client); !got[0] {
+		t.Errorf("Expected the parent to keep its sessions, got %v", got)
+	}
+
+	client = NewClient().EnableInsecureSkipVerify().DisableKeepAlives().SetTLSSessionCache(nil)
+	if got := resumed(client); got[1] {
+		t.Errorf("Expected no resumption without a session cache, got %v", got)
+	}
+}
+
+func TestResponseConnectionReused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	first, err := client.Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	second, err := client.Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if first.ConnectionReused() || first.WasIdle() {
+		t.Errorf("Expected the first request to open a new connection")
+	}
+	if !second.ConnectionReused() || !second.WasIdle() {
+		t.Errorf("Expected the second request to reuse the idle connection")
+	}
+}
+
+func TestSimpleCookies(t *testing.T) {
+	var cookies string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookies = r.Header.Get("Cookie")
+	}))
+	defer server.Close()
+
+	_, err := NewClient().
+		SetCommonSimpleCookies(map[string]string{"theme": "dark"}).
+		Get(server.URL).
+		SetSimpleCookies(map[string]string{"session": "abc", "lang": "id"}).
+		Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cookies != "theme=dark; lang=id; session=abc" {
+		t.Errorf("Unexpected Cookie header %q", cookies)
+	}
+}
+
+func TestRequestWithoutCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: r.URL.Query().Get("name"), Value: "1", Path: "/"})
+		}
+		w.Write([]byte(r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.Get(server.URL + "/login?name=session").Execute()
+
+	resp, err := client.Get(server.URL).WithoutCookieJar().SetSimpleCookies(map[string]string{"explicit": "1"}).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "explicit=1" {
+		t.Errorf("Expected only explicit cookies without the jar, got %q", resp.String())
+	}
+
+	client.Get(server.URL + "/login?name=stateless").WithoutCookieJar().Execute()
+	resp, err = client.Get(server.URL).Execute()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if resp.String() != "session=1" {
+		t.Errorf("Expected jar cookies without the stateless one, got %q", resp.String())
+	}
+}
+
+// failingReader returns data and then fails with err
+type failingReader struct {
+	data []byte
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestSetKeepPartialBody(t *testing.T) {
+	readErr := errors.New("connection reset")
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     "200 OK",
+			Header:     http.Header{},
+			Body:       io.NopCloser(&failingReader{data: []byte("partial log"), err: readErr}),
+			Request:    req,
+		}, nil
+	})
+
+	resp, err := NewClient().SetTransport(transport).Get("http://example.com/logs").Execute()
+	if !errors.Is(err, readErr) || len(resp.Body()) != 0 {
+		t.Errorf("Expected the partial body to be dropped by default, got %q (err=%v)", resp.Body(), err)
+	}
+
+	resp, err = NewClient().SetTransport(transport).SetKeepPartialBody(true).Get("http://example.com/logs").Execute()
+	if !errors.Is(err, readErr) {
+		t.Errorf("Expected the read error to be returned, got %v", err)
+	}
+	if resp.String() != "partial log" || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected partial body with status, got %d %q", resp.StatusCode, resp.String())
+	}
+}
+
+func TestTimeoutSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewClient().SetTimeout(50 * time.Millisecond).Get(server.URL).Execute()
+	if !errors.Is(err, ErrClientTimeout) {
+		t.Errorf("Expected a client timeout error, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = NewClient().SetTimeout(5 * time.Second).Get(server.URL).SetContext(ctx).Execute()
+	if errors.Is(err, ErrClientTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a context deadline error, got %v", err)
+	}
+	if err != nil && !strings.Contains(err.Error(), "request context deadline exceeded") {
+		t.Errorf("Expected the error to name the context deadline, got %v", err)
+	}
+}
+
+func TestFromCurl(t *testing.T) {
+	var method, body, query string
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, headers, query = r.Method, r.Header, r.URL.RawQuery
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+	}))
+	defer server.Close()
+
+	req, err := FromCurl(`curl '` + server.URL + `/users?page=1' \
+  -H 'Accept: application/json' \
+  -H "X-Token: \"abc\"" \
+  -u admin:secret \
+  --data-raw '{"name":"budi"}' \
+  -H 'Content-Type: application/json' --compressed`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := req.Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if method != http.MethodPost || body != `{"name":"budi"}` || query != "page=1" {
+		t.Errorf("Expected POST with JSON body, got %s %q ?%s", method, body, query)
+	}
+	if headers.Get("Accept") != "application/json" || headers.Get("X-Token") != `"abc"` || headers.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected headers from the command, got %v", headers)
+	}
+	if user, pass, ok := (&http.Request{Header: headers}).BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		t.Errorf("Expected basic auth admin:secret, got %q:%q", user, pass)
+	}
+
+	req, err = FromCurl("curl -G --url " + server.URL + " -d q=go --data-urlencode 'tag=a b' -XGET")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := req.Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if method != http.MethodGet || body != "" || query != "q=go&tag=a+b" {
+		t.Errorf("Expected data as query, got %s %q ?%s", method, body, query)
+	}
+
+	dir := t.TempDir()
+	dataFile := filepath.Join(dir, "data.txt")
+	os.WriteFile(dataFile, []byte("a=1\nb=2\n"), 0o644)
+	req, err = FromCurl("curl -sSLXPUT " + server.URL + " -d @" + dataFile + " --data-urlencode note@" + dataFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := req.Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if method != http.MethodPut || body != "a=1b=2&note=a%3D1%0Ab%3D2%0A" {
+		t.Errorf("Expected data read from the file, got %s %q", method, body)
+	}
+
+	req, err = FromCurl("curl " + server.URL + " --data-binary @" + dataFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := req.Execute(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if body != "a=1\nb=2\n" {
+		t.Errorf("Expected --data-binary to keep line breaks, got %q", body)
+	}
+
+	for _, command := range []string{"curl -H 'Accept: */*'", "curl 'http://x", "curl --proxy http://p http://x",
+		"curl -d @- http://x", "curl -d @" + filepath.Join(dir, "missing") + " http://x", "curl -sz http://x"} {
+		if _, err := FromCurl(command); err == nil {
+			t.Errorf("Expected an error for %q", command)
+		}
+	}
+}
+
+func TestToCurl(t *testing.T) {
+	client := NewClient().
+		SetBaseURL("https://api.example.com").
+		SetCommonHeader("X-App", "cumi").
+		SetBodyRedactor(func(body []byte) []byte {
+			return bytes.ReplaceAll(body, []byte("hunter2"), []byte("***"))
+		})
+	req := client.Post("/users/{id}").
+		SetPathParam("id", "42").
+		SetQueryParam("notify", "true").
+		SetBearerToken("secret-token").
+		SetBody(map[string]string{"password": "hunter2", "note": "it's"})
+
+	command := req.ToCurl()
+	want := `curl -X POST 'https://api.example.com/users/42?notify=true' -H 'Authorization: Bearer secret-token' ` +
+		`-H 'Content-Type: application/json' -H 'User-Agent: Go-http-client/1.1' -H 'X-App: cumi' ` +
+		`--data-binary '{"note":"it'\''s","password":"hunter2"}'`
+	if command != want {
+		t.Errorf("Expected %s, got %s", want, command)
+	}
+
+	redacted := req.ToCurlRedacted()
+	if strings.Contains(redacted, "secret-token") || strings.Contains(redacted, "hunter2") {
+		t.Errorf("Expected secrets to be redacted, got %s", redacted)
+	}
+
+	// The command parses back into the same request
+	parsed, err := FromCurl(command)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if parsed.Method() != http.MethodPost || parsed.headers.Get("Authorization") != "Bearer secret-token" || parsed.body != `{"note":"it's","password":"hunter2"}` {
+		t.Errorf("Expected the command to round-trip, got %s %v %v", parsed.Method(), parsed.headers, parsed.body)
+	}
+
+	if got := client.Get("/users/{id}").ToCurl(); got != "" {
+		t.Errorf("Expected an empty command for an unpreparable request, got %s", got)
+	}
+
+	// A Host header becomes the request host, so it is rendered once
+	hostReq := client.Get("/").SetHeader("Host", "internal.example.com")
+	httpReq, err := hostReq.BuildHTTPRequest()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if httpReq.Host != "internal.example.com" || httpReq.Header.Get("Host") != "" {
+		t.Errorf("Expected the Host header to set the request host, got %q and %v", httpReq.Host, httpReq.Header)
+	}
+	if got := hostReq.ToCurl(); strings.Count(got, "Host: ") != 1 {
+		t.Errorf("Expected a single Host header, got %s", got)
+	}
+}
+
+func TestTypedNetworkErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+
+	_, err = NewClient().Get(closedURL).Execute()
+	var connErr *ErrConnection
+	if !errors.As(err, &connErr) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("Expected ErrConnection for a refused connection, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	_, err = NewClient().SetTimeout(50 * time.Millisecond).Get(server.URL).Execute()
+	var timeoutErr *ErrTimeout
+	if !errors.As(err, &timeoutErr) || !timeoutErr.Timeout() {
+		t.Errorf("Expected ErrTimeout, got %v", err)
+	}
+	if errors.As(err, &connErr) {
+		t.Errorf("Expected a timeout not to be reported as ErrConnection, got %v", err)
+	}
+}
+
+func TestSetContentType(t *testing.T) {
+	var contentTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+	}))
+	defer server.Close()
+
+	client := NewClient()
+	client.Post(server.URL).SetBodyBytes([]byte("%PDF-1.7")).SetContentType("application/pdf").Execute()
+	client.Post(server.URL).SetBodyReader(strings.NewReader("GIF89a")).SetContentType("image/gif").Execute()
+	client.Post(server.URL).SetBodyJSON(map[string]int{"a": 1}).SetContentType("application/vnd.api+json").Execute()
+	client.Post(server.URL).SetBodyString("raw").SetContentType("text/csv").SetHeader("Content-Type", "text/plain").Execute()
+	client.Get(server.URL).SetContentType("application/pdf").Execute()
+
+	want := []string{"application/pdf", "image/gif", "application/vnd.api+json", "text/plain", ""}
+	if !reflect.DeepEqual(contentTypes, want) {
+		t.Errorf("Expected content types %v, got %v", want, contentTypes)
+	}
+
+	// Multipart bodies keep their boundary
+	part := func(w *multipart.Writer) error { return w.WriteField("a", "1") }
+	for _, contentType := range []string{`multipart/related; type="application/json"`, "application/pdf"} {
+		contentTypes = nil
+		client.Post(server.URL).SetBodyMultipart(part).SetContentType(contentType).Execute()
+		mediaType, params, err := mime.ParseMediaType(contentTypes[0])
+		if err != nil || params["boundary"] == "" || !strings.HasPrefix(mediaType, "multipart/") {
+			t.Errorf("Expected a multipart type with a boundary for %q, got %q", contentType, contentTypes[0])
+		}
+	}
+	if !strings.Contains(client.Post(server.URL).SetBodyMultipart(part).SetContentType("multipart/related").ToCurl(), "multipart/related; boundary=") {
+		t.Errorf("Expected multipart/related to keep the boundary")
+	}
+}
+
+func TestAcceptEncodingAndCompression(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	gw.Write([]byte("hello"))
+	gw.Close()
+
+	var acceptEncoding string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		if strings.Contains(acceptEncoding, "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(gzipped.Bytes())
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	resp, _ := NewClient().Get(server.URL).Execute()
+	if resp.String() != "hello" || acceptEncoding != "gzip" {
+		t.Errorf("Expected transparent gzip by default, got %q (Accept-Encoding %q)", resp.String(), acceptEncoding)
+	}
+
+	resp, _ = NewClient().SetAcceptEncoding("gzip", "br").Get(server.URL).Execute()
+	if !bytes.Equal(resp.Body(), gzipped.Bytes()) || resp.Header.Get("Content-Encoding") != "gzip" || acceptEncoding != "gzip, br" {
+		t.Errorf("Expected raw gzip bytes, got %q (Accept-Encoding %q)", resp.Body(), acceptEncoding)
+	}
+
+	resp, _ = NewClient().DisableCompression().Get(server.URL).Execute()
+	if resp.String() != "hello" || acceptEncoding != "" {
+		t.Errorf("Expected no compression, got %q (Accept-Encoding %q)", resp.String(), acceptEncoding)
+	}
 }
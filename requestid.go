@@ -0,0 +1,45 @@
+package cumi
+
+import "context"
+
+// requestIDKey is the context key for request IDs
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a context carrying a request ID, which clients
+// with EnableRequestID send instead of generating a new one
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// EnableRequestID sends a request ID on headerName (X-Request-ID when empty)
+// with every request. The ID is taken from the request context when present,
+// otherwise a UUID is generated, and the same ID is used for all retries.
+func (c *Client) EnableRequestID(headerName string) *Client {
+	if headerName == "" {
+		headerName = "X-Request-ID"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestIDHeader = headerName
+	return c
+}
+
+// DisableRequestID stops sending request IDs
+func (c *Client) DisableRequestID() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestIDHeader = ""
+	return c
+}
+
+// RequestID returns the request ID sent with the request, or an empty string
+// when request IDs are not enabled
+func (r *Response) RequestID() string {
+	return r.requestID
+}
@@ -1,22 +1,38 @@
 package cumi
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"google.golang.org/protobuf/proto"
 )
 
 // Response represents an HTTP response
 type Response struct {
 	Request    *Request
 	Response   *http.Response
+	requestID  string
 	body       []byte
 	size       int64
 	receivedAt time.Time
 	duration   time.Duration
 	state      ResultState
+	fromCache  bool
+	timer      *connTimer
+	history    []AttemptInfo
+	stream     io.ReadCloser
+	bytesSent  int64
+	bytesRecv  int64
+	streamRecv *atomic.Int64
 	Err        error
 
 	// Embedded from http.Response for direct access
@@ -28,21 +44,98 @@ type Response struct {
 	Header     http.Header
 }
 
+// setStatus copies the status line and headers from httpResp
+func (r *Response) setStatus(httpResp *http.Response) {
+	r.StatusCode = httpResp.StatusCode
+	r.Status = httpResp.Status
+	r.Proto = httpResp.Proto
+	r.ProtoMajor = httpResp.ProtoMajor
+	r.ProtoMinor = httpResp.ProtoMinor
+	r.Header = httpResp.Header
+}
+
+// AttemptInfo summarizes a single attempt of a request
+type AttemptInfo struct {
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
 // Body returns the response body as bytes
 func (r *Response) Body() []byte {
 	return r.body
 }
 
+// Raw returns a fresh reader over the buffered response body.
+// Each call returns a new reader, so the body can be streamed multiple times.
+// For streamed responses it returns the unread network body instead.
+func (r *Response) Raw() io.ReadCloser {
+	if r.stream != nil {
+		return r.stream
+	}
+	return io.NopCloser(bytes.NewReader(r.body))
+}
+
+// Close closes the body of a streamed response
+func (r *Response) Close() error {
+	if r.stream == nil {
+		return nil
+	}
+	return r.stream.Close()
+}
+
+// DecodeStream passes a JSON decoder over the response body to fn so large
+// documents can be decoded element by element. The body is closed afterwards.
+func (r *Response) DecodeStream(fn func(decoder *json.Decoder) error) error {
+	body := r.Raw()
+	defer body.Close()
+	return fn(json.NewDecoder(body))
+}
+
+// NDJSON calls fn for every record of a newline-delimited JSON body, skipping
+// blank lines. Streamed responses are read record by record without
+// buffering the whole body. The body is closed afterwards.
+func (r *Response) NDJSON(fn func(line json.RawMessage) error) error {
+	body := r.Raw()
+	defer body.Close()
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if record := bytes.TrimSpace(line); len(record) > 0 {
+			if fnErr := fn(json.RawMessage(record)); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // String returns the response body as a string
 func (r *Response) String() string {
 	return string(r.body)
 }
 
-// JSON unmarshals the response body into the provided interface using JSON
+// JSON unmarshals the response body into the provided interface using JSON,
+// honoring the client's UseNumber and DisallowUnknownFields options
 func (r *Response) JSON(v interface{}) error {
 	if len(r.body) == 0 {
 		return nil
 	}
+	if r.Request != nil && r.Request.client != nil {
+		c := r.Request.client
+		c.mu.RLock()
+		decode := c.jsonDecoder(r.Request)
+		c.mu.RUnlock()
+		if decode != nil {
+			return decode(r.body, v)
+		}
+	}
 	return json.Unmarshal(r.body, v)
 }
 
@@ -54,11 +147,47 @@ func (r *Response) XML(v interface{}) error {
 	return xml.Unmarshal(r.body, v)
 }
 
+// Protobuf unmarshals the response body into the provided protobuf message.
+// It is not named Proto because that name is taken by the protocol field.
+func (r *Response) Protobuf(msg proto.Message) error {
+	if len(r.body) == 0 {
+		return nil
+	}
+	return proto.Unmarshal(r.body, msg)
+}
+
+// Unmarshal unmarshals the response body using the client's decoder for the
+// response Content-Type, returning an error for unregistered content types
+func (r *Response) Unmarshal(v interface{}) error {
+	if r.Request == nil || r.Request.client == nil {
+		return fmt.Errorf("response has no client to resolve a decoder")
+	}
+	return r.Request.client.unmarshalResponse(r, v)
+}
+
+// Bind unmarshals the body into success or errorResult depending on the
+// result state, like SetSuccessResult and SetErrorResult do during Execute.
+// A nil destination or another state leaves the body unread.
+func (r *Response) Bind(success, errorResult interface{}) error {
+	switch {
+	case r.state == SuccessState && success != nil:
+		return r.Unmarshal(success)
+	case r.state == ErrorState && errorResult != nil:
+		return r.Unmarshal(errorResult)
+	}
+	return nil
+}
+
 // IsSuccess returns true if the response is successful (2xx status code)
 func (r *Response) IsSuccess() bool {
 	return r.state == SuccessState
 }
 
+// IsNotModified returns true if the response status is 304 Not Modified
+func (r *Response) IsNotModified() bool {
+	return r.StatusCode == http.StatusNotModified
+}
+
 // IsError returns true if the response is an error (4xx or 5xx status code)
 func (r *Response) IsError() bool {
 	return r.state == ErrorState
@@ -74,11 +203,34 @@ func (r *Response) Duration() time.Duration {
 	return r.duration
 }
 
+// TraceInfo returns DNS, connect, TLS and time-to-first-byte timings for the final attempt
+func (r *Response) TraceInfo() TraceInfo {
+	if r.timer == nil {
+		return TraceInfo{}
+	}
+	return r.timer.traceInfo()
+}
+
+// ConnectionReused reports whether the final attempt reused a pooled connection
+func (r *Response) ConnectionReused() bool {
+	return r.TraceInfo().IsConnReused
+}
+
+// WasIdle reports whether the reused connection had been idle in the pool
+func (r *Response) WasIdle() bool {
+	return r.TraceInfo().IsConnWasIdle
+}
+
 // Size returns the size of the response body in bytes
 func (r *Response) Size() int64 {
 	return r.size
 }
 
+// IsFromCache returns true if the body was served from the response cache after a 304
+func (r *Response) IsFromCache() bool {
+	return r.fromCache
+}
+
 // ResultState returns the state of the response
 func (r *Response) ResultState() ResultState {
 	return r.state
@@ -94,15 +246,17 @@ func (r *Response) ContentType() string {
 	return r.Header.Get("Content-Type")
 }
 
-// IsJSON returns true if the response content type is JSON
+// IsJSON returns true if the response content type is JSON, sniffing the
+// body when content sniffing is enabled
 func (r *Response) IsJSON() bool {
-	contentType := r.ContentType()
+	contentType := r.effectiveContentType()
 	return strings.Contains(contentType, "application/json")
 }
 
-// IsXML returns true if the response content type is XML
+// IsXML returns true if the response content type is XML, sniffing the
+// body when content sniffing is enabled
 func (r *Response) IsXML() bool {
-	contentType := r.ContentType()
+	contentType := r.effectiveContentType()
 	return strings.Contains(contentType, "application/xml") ||
 		strings.Contains(contentType, "text/xml")
 }
@@ -119,6 +273,11 @@ func (r *Response) IsText() bool {
 	return strings.Contains(contentType, "text/plain")
 }
 
+// IsHTTP2 returns true if the response was received over HTTP/2
+func (r *Response) IsHTTP2() bool {
+	return r.ProtoMajor == 2
+}
+
 // Cookies returns the cookies set by the server
 func (r *Response) Cookies() []*http.Cookie {
 	if r.Response == nil {
@@ -127,7 +286,79 @@ func (r *Response) Cookies() []*http.Cookie {
 	return r.Response.Cookies()
 }
 
+// FinalURL returns the URL of the last request sent, after following redirects
+func (r *Response) FinalURL() string {
+	if r.Response != nil && r.Response.Request != nil {
+		return r.Response.Request.URL.String()
+	}
+	if r.Request != nil {
+		return r.Request.URL()
+	}
+	return ""
+}
+
+// RedirectCount returns the number of redirects followed to get this response
+func (r *Response) RedirectCount() int {
+	if r.Response == nil {
+		return 0
+	}
+	count := 0
+	for req := r.Response.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		count++
+	}
+	return count
+}
+
 // Location returns the Location header value (useful for redirects)
 func (r *Response) Location() string {
 	return r.Header.Get("Location")
 }
+
+// ContentLength returns the Content-Length header value, or -1 if it is missing or invalid
+func (r *Response) ContentLength() int64 {
+	n, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// LastModified parses the Last-Modified header
+func (r *Response) LastModified() (time.Time, error) {
+	value := r.Header.Get("Last-Modified")
+	if value == "" {
+		return time.Time{}, fmt.Errorf("Last-Modified header not set")
+	}
+	return http.ParseTime(value)
+}
+
+// ETag returns the ETag header value
+func (r *Response) ETag() string {
+	return r.Header.Get("ETag")
+}
+
+// Trailers returns the trailer headers sent after the response body. They are
+// available once the body has been fully read, so for streamed responses only
+// after the stream reaches EOF.
+func (r *Response) Trailers() http.Header {
+	if r.Response == nil || r.Response.Trailer == nil {
+		return http.Header{}
+	}
+	return r.Response.Trailer
+}
+
+// Attempts returns the number of attempts made, including retries
+func (r *Response) Attempts() int {
+	return len(r.history)
+}
+
+// AttemptHistory returns a summary of every attempt made, in order.
+// The last entry describes this response.
+func (r *Response) AttemptHistory() []AttemptInfo {
+	return r.history
+}
+
+// attemptInfo summarizes this response as a single attempt
+func (r *Response) attemptInfo() AttemptInfo {
+	return AttemptInfo{StatusCode: r.StatusCode, Err: r.Err, Duration: r.duration}
+}
@@ -0,0 +1,235 @@
+package cumi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm       = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat      = "20060102T150405Z"
+	sigV4DateFormat      = "20060102"
+	sigV4UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+// sigV4Now returns the signing time; replaced in tests
+var sigV4Now = time.Now
+
+// awsSigV4 holds the credentials and scope used to sign a request with AWS Signature Version 4
+type awsSigV4 struct {
+	accessKey      string
+	secretKey      string
+	sessionToken   string
+	region         string
+	service        string
+	presignExpires time.Duration
+}
+
+// signAWSSigV4 signs the request in place, either with an Authorization header
+// or, when presignExpires is set, with query string parameters
+func signAWSSigV4(httpReq *http.Request, cfg *awsSigV4, now time.Time) error {
+	now = now.UTC()
+	amzDate := now.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{now.Format(sigV4DateFormat), cfg.region, cfg.service, "aws4_request"}, "/")
+
+	if cfg.presignExpires > 0 {
+		return presignAWSSigV4(httpReq, cfg, amzDate, scope)
+	}
+
+	payloadHash, err := sigV4PayloadHash(httpReq)
+	if err != nil {
+		return err
+	}
+
+	httpReq.Header.Set("X-Amz-Date", amzDate)
+	if cfg.sessionToken != "" {
+		httpReq.Header.Set("X-Amz-Security-Token", cfg.sessionToken)
+	}
+	if cfg.service == "s3" {
+		httpReq.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4CanonicalHeaders(httpReq)
+	canonicalRequest := strings.Join([]string{
+		httpReq.Method,
+		sigV4CanonicalURI(httpReq.URL, cfg.service),
+		sigV4CanonicalQuery(httpReq.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	signature := sigV4Signature(cfg, amzDate, scope, canonicalRequest)
+	httpReq.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, cfg.accessKey, scope, signedHeaders, signature))
+	return nil
+}
+
+// presignAWSSigV4 adds the signature and its parameters to the request query string
+func presignAWSSigV4(httpReq *http.Request, cfg *awsSigV4, amzDate, scope string) error {
+	query := httpReq.URL.Query()
+	query.Set("X-Amz-Algorithm", sigV4Algorithm)
+	query.Set("X-Amz-Credential", cfg.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(cfg.presignExpires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if cfg.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", cfg.sessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		httpReq.Method,
+		sigV4CanonicalURI(httpReq.URL, cfg.service),
+		sigV4CanonicalQuery(query),
+		"host:" + sigV4Host(httpReq) + "\n",
+		"host",
+		sigV4UnsignedPayload,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", sigV4Signature(cfg, amzDate, scope, canonicalRequest))
+	httpReq.URL.RawQuery = sigV4CanonicalQuery(query)
+	return nil
+}
+
+// sigV4Signature derives the signing key and signs the canonical request
+func sigV4Signature(cfg *awsSigV4, amzDate, scope, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+cfg.secretKey), amzDate[:8])
+	key = hmacSHA256(key, cfg.region)
+	key = hmacSHA256(key, cfg.service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// sigV4PayloadHash hashes the exact request body bytes, falling back to an
+// unsigned payload when the body cannot be read again
+func sigV4PayloadHash(httpReq *http.Request) (string, error) {
+	h := sha256.New()
+	if httpReq.Body != nil && httpReq.Body != http.NoBody {
+		if httpReq.GetBody == nil {
+			return sigV4UnsignedPayload, nil
+		}
+		body, err := httpReq.GetBody()
+		if err != nil {
+			return "", fmt.Errorf("failed to read body for signing: %w", err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", fmt.Errorf("failed to read body for signing: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// sigV4CanonicalHeaders returns the signed header list and canonical header block.
+// Host, Content-Type and all X-Amz-* headers are signed.
+func sigV4CanonicalHeaders(httpReq *http.Request) (string, string) {
+	headers := map[string]string{"host": sigV4Host(httpReq)}
+	for key, values := range httpReq.Header {
+		lower := strings.ToLower(key)
+		if lower != "content-type" && !strings.HasPrefix(lower, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		value := strings.Join(trimmed, ",")
+		if value == "" {
+			continue
+		}
+		headers[lower] = value
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// sigV4Host returns the host used for signing
+func sigV4Host(httpReq *http.Request) string {
+	if httpReq.Host != "" {
+		return httpReq.Host
+	}
+	return httpReq.URL.Host
+}
+
+// sigV4CanonicalURI encodes the path; services other than S3 expect it encoded twice
+func sigV4CanonicalURI(u *url.URL, service string) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segment = awsURIEncode(segment, false)
+		if service != "s3" {
+			segment = awsURIEncode(segment, false)
+		}
+		segments[i] = segment
+	}
+	return strings.Join(segments, "/")
+}
+
+// sigV4CanonicalQuery sorts and encodes query parameters
+func sigV4CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode percent-encodes everything except unreserved characters
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case (ch >= 'A' && ch <= 'Z') || (ch >= 'a' && ch <= 'z') || (ch >= '0' && ch <= '9'),
+			ch == '-', ch == '_', ch == '.', ch == '~':
+			b.WriteByte(ch)
+		case ch == '/' && !encodeSlash:
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+// hmacSHA256 computes HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
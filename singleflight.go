@@ -0,0 +1,77 @@
+package cumi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// EnableSingleFlight coalesces concurrent identical requests so only one is
+// sent and every caller shares its response. It applies to GET and HEAD
+// unless other methods are given. Requests are matched by Fingerprint.
+// Streamed requests and requests writing to a file or writer are never
+// coalesced, as their body can only be consumed once.
+func (c *Client) EnableSingleFlight(methods ...string) *Client {
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodHead}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.singleFlight = make(map[string]bool)
+	for _, method := range methods {
+		c.singleFlight[strings.ToUpper(method)] = true
+	}
+	return c
+}
+
+// DisableSingleFlight stops coalescing concurrent identical requests
+func (c *Client) DisableSingleFlight() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.singleFlight = nil
+	return c
+}
+
+// useSingleFlight reports whether req should be coalesced with identical requests
+func (c *Client) useSingleFlight(req *Request) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.singleFlight[req.method] && !req.stream && req.downloadPath == "" && req.writer == nil
+}
+
+// executeShared executes req through the single-flight group. The shared call
+// runs detached from the caller's cancellation, so a caller giving up does not
+// cancel it for the others; each caller still returns as soon as its own
+// context is done. Results are bound separately for every caller.
+func (c *Client) executeShared(req *Request) (*Response, error) {
+	key := req.Fingerprint()
+	if key == "" {
+		return c.execute(req)
+	}
+
+	ch := c.flightGroup.DoChan(key, func() (interface{}, error) {
+		shared := req.Clone()
+		shared.ctx = context.WithoutCancel(req.Context())
+		shared.successResult = nil
+		shared.errorResult = nil
+		return c.execute(shared)
+	})
+
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case result := <-ch:
+		resp, _ := result.Val.(*Response)
+		if resp == nil {
+			return nil, result.Err
+		}
+
+		own := *resp
+		own.Request = req
+		own.Header = resp.Header.Clone()
+		if own.Err == nil {
+			c.bindResult(req, &own)
+		}
+		return &own, own.Err
+	}
+}
@@ -0,0 +1,59 @@
+package cumi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// EnableContentSniffing makes IsJSON, IsXML and result binding detect the
+// body type when the Content-Type header is missing, text/plain or
+// application/octet-stream, for servers that mislabel their responses
+func (c *Client) EnableContentSniffing() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sniffContent = true
+	return c
+}
+
+// DisableContentSniffing only trusts the Content-Type header (the default)
+func (c *Client) DisableContentSniffing() *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sniffContent = false
+	return c
+}
+
+// DetectContentType sniffs the content type from the body bytes, ignoring the
+// Content-Type header. JSON objects and arrays are reported as application/json,
+// anything else uses http.DetectContentType.
+func (r *Response) DetectContentType() string {
+	trimmed := bytes.TrimSpace(r.body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return "application/json"
+	}
+	return http.DetectContentType(r.body)
+}
+
+// effectiveContentType returns the Content-Type header, or the sniffed type
+// when content sniffing is enabled and the header is missing or generic
+func (r *Response) effectiveContentType() string {
+	contentType := r.ContentType()
+	if r.Request == nil || r.Request.client == nil || len(r.body) == 0 {
+		return contentType
+	}
+
+	c := r.Request.client
+	c.mu.RLock()
+	sniff := c.sniffContent
+	c.mu.RUnlock()
+	if !sniff {
+		return contentType
+	}
+
+	switch normalizeMediaType(contentType) {
+	case "", "text/plain", "application/octet-stream":
+		return r.DetectContentType()
+	}
+	return contentType
+}
@@ -0,0 +1,208 @@
+package cumi
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// fieldOptions holds the options parsed from a struct field's tags
+type fieldOptions struct {
+	name      string
+	tagged    bool // name came from a tag rather than the field name
+	omitEmpty bool
+	unix      bool
+	layout    string
+}
+
+// parseFieldTag parses a "name,opt,opt" tag, falling back to the field name.
+// Known options are omitempty and unix; the time layout comes from the `layout` tag.
+func parseFieldTag(field reflect.StructField, tag string) fieldOptions {
+	parts := strings.Split(tag, ",")
+	opts := fieldOptions{name: parts[0], tagged: parts[0] != "", layout: field.Tag.Get("layout")}
+	if opts.name == "" {
+		opts.name = field.Name
+	}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			opts.omitEmpty = true
+		case "unix":
+			opts.unix = true
+		}
+	}
+	return opts
+}
+
+// structEncoder reflects over a struct and adds its fields to url.Values.
+// Form data and query params share it and differ only in how fields are
+// named and how slices of scalars are keyed.
+//
+// Nested structs and string-keyed maps are encoded as parent[child], slices
+// of structs and maps as key[0][child], and exported embedded structs without a
+// name tag are flattened into their parent. time.Time is formatted with the
+// `layout` tag (RFC3339 by default) or as Unix seconds with ",unix".
+type structEncoder struct {
+	source      string // what is being encoded, for errors
+	fieldOpts   func(field reflect.StructField) (opts fieldOptions, skip bool)
+	sliceSuffix string // appended to the key of each scalar slice element
+}
+
+// encode adds the fields of the struct v (or a pointer to it) to values
+func (e *structEncoder) encode(values url.Values, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("%s source must be a struct, got %s", e.source, rv.Kind())
+	}
+	return e.encodeFields(values, rv, "")
+}
+
+// encodeFields encodes the exported fields of a struct value using prefix as the parent key
+func (e *structEncoder) encodeFields(values url.Values, rv reflect.Value, prefix string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		opts, skip := e.fieldOpts(field)
+		if skip {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if field.Anonymous && !opts.tagged && isEmbeddedStruct(field.Type) {
+			for fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					break
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := e.encodeFields(values, fv, prefix); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		key := opts.name
+		if prefix != "" {
+			key = prefix + "[" + key + "]"
+		}
+		if opts.omitEmpty && fv.IsZero() {
+			continue
+		}
+		if err := e.encodeValue(values, key, fv, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeValue adds a single value, expanding structs, maps and slices under key
+func (e *structEncoder) encodeValue(values url.Values, key string, fv reflect.Value, opts fieldOptions) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+
+	if fv.Type() == timeType {
+		t := fv.Interface().(time.Time)
+		switch {
+		case opts.unix:
+			values.Add(key, strconv.FormatInt(t.Unix(), 10))
+		case opts.layout != "":
+			values.Add(key, t.Format(opts.layout))
+		default:
+			values.Add(key, t.Format(time.RFC3339))
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Struct:
+		return e.encodeFields(values, fv, key)
+	case reflect.Map:
+		if fv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported %s map key type %s for %q", e.source, fv.Type().Key(), key)
+		}
+		iter := fv.MapRange()
+		for iter.Next() {
+			if err := e.encodeValue(values, key+"["+iter.Key().String()+"]", iter.Value(), opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		if fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Uint8 {
+			values.Add(key, string(fv.Bytes()))
+			return nil
+		}
+		for i := 0; i < fv.Len(); i++ {
+			elemKey := key + e.sliceSuffix
+			if isComposite(fv.Type().Elem()) {
+				elemKey = key + "[" + strconv.Itoa(i) + "]"
+			}
+			if err := e.encodeValue(values, elemKey, fv.Index(i), opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	values.Add(key, formatScalar(fv))
+	return nil
+}
+
+// isComposite reports whether values of t expand into several keys
+func isComposite(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Map || t.Kind() == reflect.Struct && t != timeType
+}
+
+// isEmbeddedStruct reports whether an embedded field of type t is flattened
+func isEmbeddedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// formatScalar formats a scalar value as a form or query string value
+func formatScalar(fv reflect.Value) string {
+	if s, ok := fv.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String()
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(fv.Uint(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 32)
+	case reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 64)
+	}
+	return fmt.Sprint(fv.Interface())
+}
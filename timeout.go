@@ -0,0 +1,87 @@
+package cumi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrClientTimeout is wrapped by errors caused by the client timeout
+// (SetTimeout) rather than by the request context deadline. net/http reports
+// both as context.DeadlineExceeded, so use errors.Is(err, ErrClientTimeout) to
+// tell them apart.
+var ErrClientTimeout = errors.New("client timeout exceeded")
+
+// ErrTimeout is returned when a request times out, whether because of the
+// client timeout, the request context deadline or a dial or TLS handshake
+// timeout. Use errors.As to detect it.
+type ErrTimeout struct {
+	Err error
+}
+
+// Error returns the underlying error message
+func (e *ErrTimeout) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ErrTimeout) Unwrap() error {
+	return e.Err
+}
+
+// Timeout reports true, matching net.Error
+func (e *ErrTimeout) Timeout() bool {
+	return true
+}
+
+// ErrConnection is returned when a connection to the server could not be
+// established, such as when it is refused or the host can't be resolved. Use
+// errors.As to detect it.
+type ErrConnection struct {
+	Err error
+}
+
+// Error returns the underlying error message
+func (e *ErrConnection) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *ErrConnection) Unwrap() error {
+	return e.Err
+}
+
+// classifyError wraps err from an attempt started at start in ErrTimeout or
+// ErrConnection when it is one of those. Timeouts are annotated with the
+// deadline that fired: net/http enforces both the client timeout and the
+// context deadline, so the earlier of the two is the one that ended the
+// attempt.
+func (c *Client) classifyError(ctx context.Context, start time.Time, err error) error {
+	c.mu.RLock()
+	clientTimeout := c.httpClient.Timeout
+	c.mu.RUnlock()
+
+	deadline, hasDeadline := ctx.Deadline()
+	contextFirst := hasDeadline && (clientTimeout <= 0 || deadline.Before(start.Add(clientTimeout)))
+	if contextFirst && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &ErrTimeout{Err: fmt.Errorf("request context deadline exceeded after %s: %w", deadline.Sub(start).Round(time.Millisecond), err)}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		if !contextFirst && clientTimeout > 0 && time.Since(start) >= clientTimeout {
+			return &ErrTimeout{Err: fmt.Errorf("%w after %s: %w", ErrClientTimeout, clientTimeout, err)}
+		}
+		return &ErrTimeout{Err: err}
+	}
+
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.As(err, &dnsErr) || errors.As(err, &opErr) && opErr.Op == "dial" {
+		return &ErrConnection{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,211 @@
+package cumi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCertPinMismatch is returned when no server certificate matches a pinned fingerprint
+var ErrCertPinMismatch = errors.New("certificate pinning mismatch")
+
+// SetClientCertificates adds a client certificate for mutual TLS from PEM encoded
+// certificate and key. Load errors are returned when a request is executed,
+// until a certificate loads successfully.
+func (c *Client) SetClientCertificates(certPEM, keyPEM []byte) *Client {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return c.setErr("clientCert", fmt.Errorf("failed to load client certificate: %w", err))
+	}
+	return c.addClientCertificate(cert)
+}
+
+// SetClientCertFromFile adds a client certificate for mutual TLS from PEM files.
+// Load errors are returned when a request is executed, until a certificate
+// loads successfully.
+func (c *Client) SetClientCertFromFile(certPath, keyPath string) *Client {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return c.setErr("clientCert", fmt.Errorf("failed to load client certificate: %w", err))
+	}
+	return c.addClientCertificate(cert)
+}
+
+// SetTLSSessionCache sets the cache used to resume TLS sessions, so new
+// connections to a known host skip the full handshake. Default clients use an
+// LRU cache; nil disables resumption. The cache is replaced by an empty LRU
+// cache when client certificates or root CAs change, and clones start with
+// their own empty cache.
+func (c *Client) SetTLSSessionCache(cache tls.ClientSessionCache) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if tlsConfig := c.transportTLSConfig(); tlsConfig != nil {
+		tlsConfig.ClientSessionCache = cache
+	}
+	return c
+}
+
+// SetRootCAs adds PEM encoded CA certificates to the pool used to verify servers.
+// Parse errors are returned when a request is executed, until valid
+// certificates are added.
+func (c *Client) SetRootCAs(pemBytes []byte) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tlsConfig := c.transportTLSConfig()
+	if tlsConfig == nil {
+		return c
+	}
+	var pool *x509.CertPool
+	if tlsConfig.RootCAs != nil {
+		// Copy the pool, as clones share it with the parent
+		pool = tlsConfig.RootCAs.Clone()
+	} else if systemPool, err := x509.SystemCertPool(); err == nil {
+		pool = systemPool
+	} else {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		c.storeErr("rootCAs", fmt.Errorf("failed to parse root CA certificates"))
+		return c
+	}
+	c.storeErr("rootCAs", nil)
+	tlsConfig.RootCAs = pool
+	resetSessionCache(tlsConfig)
+	return c
+}
+
+// SetCertPinning pins the server certificate to SHA-256 fingerprints of either
+// the certificate DER or its SubjectPublicKeyInfo. Fingerprints may be hex
+// (optionally colon separated) or base64, with an optional "sha256/" prefix.
+// Pinning runs after normal chain verification and matches any certificate in
+// the verified chain, or only the leaf when verification is disabled.
+// Certificates the server sends outside the verified chain never match.
+func (c *Client) SetCertPinning(sha256Fingerprints ...string) *Client {
+	pins := make([][]byte, 0, len(sha256Fingerprints))
+	for _, fp := range sha256Fingerprints {
+		pin, err := parseFingerprint(fp)
+		if err != nil {
+			return c.setErr("certPinning", err)
+		}
+		pins = append(pins, pin)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.storeErr("certPinning", nil)
+	tlsConfig := c.transportTLSConfig()
+	if tlsConfig == nil {
+		return c
+	}
+	// VerifyConnection also runs for resumed sessions, unlike VerifyPeerCertificate
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		var candidates []*x509.Certificate
+		for _, chain := range cs.VerifiedChains {
+			candidates = append(candidates, chain...)
+		}
+		if len(cs.VerifiedChains) == 0 && len(cs.PeerCertificates) > 0 {
+			candidates = cs.PeerCertificates[:1]
+		}
+		for _, cert := range candidates {
+			certSum := sha256.Sum256(cert.Raw)
+			spkiSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(pin, certSum[:]) || bytes.Equal(pin, spkiSum[:]) {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: no verified certificate matches the %d pinned fingerprint(s)", ErrCertPinMismatch, len(pins))
+	}
+	return c
+}
+
+// parseFingerprint decodes a hex or base64 SHA-256 fingerprint
+func parseFingerprint(fp string) ([]byte, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(fp), "sha256/")
+	if b, err := hex.DecodeString(strings.ReplaceAll(value, ":", "")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(value); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid SHA-256 fingerprint %q", fp)
+}
+
+// addClientCertificate appends a certificate to the transport's TLS config
+func (c *Client) addClientCertificate(cert tls.Certificate) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.storeErr("clientCert", nil)
+	if tlsConfig := c.transportTLSConfig(); tlsConfig != nil {
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+		resetSessionCache(tlsConfig)
+	}
+	return c
+}
+
+// resetSessionCache replaces the TLS session cache with an empty one, so
+// sessions established with other certificates or roots aren't resumed.
+// Disabled (nil) caches stay disabled.
+func resetSessionCache(tlsConfig *tls.Config) {
+	if tlsConfig.ClientSessionCache != nil {
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+}
+
+// transportTLSConfig returns the transport's TLS config, creating it if needed.
+// It returns nil for custom transports. Callers must hold c.mu.
+func (c *Client) transportTLSConfig() *tls.Config {
+	transport, ok := c.httpTransport()
+	if !ok {
+		return nil
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	return transport.TLSClientConfig
+}
+
+// settingErr is a configuration error recorded for a client setting
+type settingErr struct {
+	setting string
+	err     error
+}
+
+// setErr records a configuration error for setting that is returned when a
+// request is executed. A nil err clears it, once the setting succeeds.
+func (c *Client) setErr(setting string, err error) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.storeErr(setting, err)
+	return c
+}
+
+// storeErr is setErr for callers that hold c.mu
+func (c *Client) storeErr(setting string, err error) {
+	for i, e := range c.errs {
+		if e.setting == setting {
+			c.errs = append(c.errs[:i:i], c.errs[i+1:]...)
+			break
+		}
+	}
+	if err != nil {
+		c.errs = append(c.errs, settingErr{setting, err})
+	}
+}
+
+// configErr returns the first recorded configuration error
+func (c *Client) configErr() error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.errs) == 0 {
+		return nil
+	}
+	return c.errs[0].err
+}
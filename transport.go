@@ -0,0 +1,71 @@
+package cumi
+
+import "net/http"
+
+// TransportWrapper decorates a RoundTripper, e.g. otelhttp.NewTransport
+type TransportWrapper func(http.RoundTripper) http.RoundTripper
+
+// WrapTransport adds a RoundTripper middleware around the client's transport.
+// Each call wraps the previous ones, so the last wrapper added runs first.
+// Wrappers also see redirect requests, are rebuilt when SetTransport replaces
+// the transport, and do not apply to transports set with Request.SetTransport.
+func (c *Client) WrapTransport(wrapper TransportWrapper) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.transportWraps = append(c.transportWraps, wrapper)
+	c.buildWrappedTransport()
+	return c
+}
+
+// buildWrappedTransport applies the transport wrappers to the current
+// transport. Callers must hold c.mu.
+func (c *Client) buildWrappedTransport() {
+	if len(c.transportWraps) == 0 {
+		c.wrappedRT = nil
+		return
+	}
+
+	rt := c.httpClient.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for _, wrap := range c.transportWraps {
+		rt = wrap(rt)
+	}
+	c.wrappedRT = rt
+}
+
+// HTTPTransportProvider is implemented by custom transports that send requests
+// through an *http.Transport, such as the http3 module's. Client setters for
+// proxies, TLS, timeouts, keep-alives and compression configure the transport
+// it returns instead of doing nothing.
+type HTTPTransportProvider interface {
+	HTTPTransport() *http.Transport
+}
+
+// TransportCloner is implemented by custom transports that Clone should copy
+// instead of replacing them with a default transport
+type TransportCloner interface {
+	CloneTransport() http.RoundTripper
+}
+
+// httpTransport returns the *http.Transport configured by the client's
+// setters, if any. Callers must hold c.mu.
+func (c *Client) httpTransport() (*http.Transport, bool) {
+	switch t := c.httpClient.Transport.(type) {
+	case *http.Transport:
+		return t, true
+	case HTTPTransportProvider:
+		transport := t.HTTPTransport()
+		return transport, transport != nil
+	}
+	return nil, false
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,8 +1,18 @@
 package cumi
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 )
 
 // defaultResultChecker checks the state of the response based on status code
@@ -16,30 +26,140 @@ func defaultResultChecker(resp *Response) ResultState {
 	return UnknownState
 }
 
-// buildURL builds the final URL with base URL, path params, and query params
-func (c *Client) buildURL(rawURL string, pathParams map[string]string, queryParams url.Values) (*url.URL, error) {
-	finalURL := rawURL
+// SuccessOn returns a result checker that treats the given status codes as
+// success and falls back to the default checker for everything else
+func SuccessOn(codes ...int) func(*Response) ResultState {
+	return func(resp *Response) ResultState {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return SuccessState
+			}
+		}
+		return defaultResultChecker(resp)
+	}
+}
+
+// ErrorOn returns a result checker that treats the given status codes as
+// errors and falls back to the default checker for everything else
+func ErrorOn(codes ...int) func(*Response) ResultState {
+	return func(resp *Response) ResultState {
+		for _, code := range codes {
+			if resp.StatusCode == code {
+				return ErrorState
+			}
+		}
+		return defaultResultChecker(resp)
+	}
+}
 
-	// Add base URL if relative
-	if !strings.HasPrefix(rawURL, "http") && c.baseURL != "" {
-		finalURL = c.baseURL + "/" + strings.TrimLeft(rawURL, "/")
+// SuccessRange returns a result checker that treats status codes from min to
+// max (inclusive) as success, 4xx and 5xx as errors and anything else as unknown
+func SuccessRange(min, max int) func(*Response) ResultState {
+	return func(resp *Response) ResultState {
+		if resp.StatusCode >= min && resp.StatusCode <= max {
+			return SuccessState
+		}
+		if resp.StatusCode >= 400 {
+			return ErrorState
+		}
+		return UnknownState
 	}
+}
 
+// ChainResultCheckers returns a result checker that asks each checker in
+// order and returns the first state that is not UnknownState
+func ChainResultCheckers(checkers ...func(*Response) ResultState) func(*Response) ResultState {
+	return func(resp *Response) ResultState {
+		for _, check := range checkers {
+			if state := check(resp); state != UnknownState {
+				return state
+			}
+		}
+		return UnknownState
+	}
+}
+
+// defaultRedactHeaders returns the headers masked in debug output by default
+func defaultRedactHeaders() map[string]bool {
+	return map[string]bool{
+		"Authorization": true,
+		"Cookie":        true,
+		"Set-Cookie":    true,
+	}
+}
+
+// redactHeader masks the value of sensitive headers for debug output
+func (c *Client) redactHeader(key, value string) string {
+	if c.redactHeaders[http.CanonicalHeaderKey(key)] {
+		return "[REDACTED]"
+	}
+	return value
+}
+
+// redactBody applies the body redactor for debug output
+func (c *Client) redactBody(body []byte) []byte {
+	if c.bodyRedactor == nil {
+		return body
+	}
+	return c.bodyRedactor(body)
+}
+
+// pathParamPattern matches a {name} placeholder left in a URL template
+var pathParamPattern = regexp.MustCompile(`\{[A-Za-z0-9_.-]+\}`)
+
+// missingPathParams returns the names of placeholders left in the URL, in order of appearance
+func missingPathParams(rawURL string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, placeholder := range pathParamPattern.FindAllString(rawURL, -1) {
+		name := strings.Trim(placeholder, "{}")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// escapePathParam path-escapes a path parameter value. "." and ".." are
+// escaped as well, so they stay literal segments instead of being resolved
+// as dot segments that move the request to another path.
+func escapePathParam(value string) string {
+	switch value {
+	case ".":
+		return "%2E"
+	case "..":
+		return "%2E%2E"
+	}
+	return url.PathEscape(value)
+}
+
+// buildURL builds the final URL for a request with base URL, path params, and query params
+func (c *Client) buildURL(req *Request) (*url.URL, error) {
 	// Replace path parameters
 	allPathParams := make(map[string]string)
 	for k, v := range c.pathParams {
 		allPathParams[k] = v
 	}
-	for k, v := range pathParams {
+	for k, v := range req.pathParams {
 		allPathParams[k] = v
 	}
 
+	baseURL, finalURL := c.baseURL, req.url
+	if req.baseURL != "" {
+		baseURL = req.baseURL
+	}
 	for key, value := range allPathParams {
 		placeholder := "{" + key + "}"
+		baseURL = strings.ReplaceAll(baseURL, placeholder, value)
 		finalURL = strings.ReplaceAll(finalURL, placeholder, value)
 	}
 
-	u, err := url.Parse(finalURL)
+	if missing := missingPathParams(baseURL + finalURL); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingPathParam, strings.Join(missing, ", "))
+	}
+
+	u, err := joinURL(baseURL, finalURL)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +171,7 @@ func (c *Client) buildURL(rawURL string, pathParams map[string]string, queryPara
 			q.Add(k, v)
 		}
 	}
-	for k, values := range queryParams {
+	for k, values := range req.queryParams {
 		for _, v := range values {
 			q.Add(k, v)
 		}
@@ -61,10 +181,132 @@ func (c *Client) buildURL(rawURL string, pathParams map[string]string, queryPara
 	return u, nil
 }
 
+// joinURL resolves rawURL against baseURL per RFC 3986. The base path is
+// treated as a directory, and a leading "/" in rawURL stays under it, so
+// "https://api.example.com/v2" + "/users" gives "https://api.example.com/v2/users".
+// Absolute URLs are returned unchanged.
+func joinURL(baseURL, rawURL string) (*url.URL, error) {
+	ref, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if baseURL == "" || ref.IsAbs() {
+		return ref, nil
+	}
+
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+		if base.RawPath != "" {
+			base.RawPath += "/"
+		}
+	}
+	if ref.Host == "" {
+		ref.Path = strings.TrimLeft(ref.Path, "/")
+		ref.RawPath = strings.TrimLeft(ref.RawPath, "/")
+	}
+	return base.ResolveReference(ref), nil
+}
+
+// normalizeBaseURL validates a base URL and lowercases its scheme and host.
+// Path parameter placeholders are allowed anywhere and left untouched.
+func normalizeBaseURL(baseURL string) (string, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(pathParamPattern.ReplaceAllString(baseURL, "x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("invalid base URL %q: missing scheme or host", baseURL)
+	}
+	if pathParamPattern.MatchString(baseURL) {
+		return baseURL, nil
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return u.String(), nil
+}
+
+// retrySettings returns the retry count, interval and condition for a request,
+// preferring request-level overrides, then host config, over the client defaults
+func (c *Client) retrySettings(req *Request) (int, time.Duration, RetryConditionFullFunc) {
+	c.mu.RLock()
+	count, interval, condition := c.retryCount, c.retryInterval, c.retryCondition
+	c.mu.RUnlock()
+
+	if hostCfg := c.hostConfigFor(req); hostCfg != nil {
+		if hostCfg.RetryCount != nil {
+			count = *hostCfg.RetryCount
+		}
+		if hostCfg.RetryInterval != nil {
+			interval = *hostCfg.RetryInterval
+		}
+		if hostCfg.RetryCondition != nil {
+			condition = fullRetryCondition(hostCfg.RetryCondition)
+		}
+	}
+
+	if req.retryCount != nil {
+		count = *req.retryCount
+	}
+	if req.retryInterval != nil {
+		interval = *req.retryInterval
+	}
+	if req.retryCondition != nil {
+		condition = req.retryCondition
+	}
+	return count, interval, condition
+}
+
+// readBody reads a response body, enforcing the client's maximum body size.
+// Bodies decompressed by the transport use the decompressed size limit when set.
+// On read errors the bytes read so far are returned with the error.
+func (c *Client) readBody(body io.Reader, decompressed bool) ([]byte, error) {
+	limit := c.maxBodySize
+	if decompressed && c.maxDecompressed > 0 {
+		limit = c.maxDecompressed
+	}
+	if limit <= 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err != nil {
+		return data, err
+	}
+	if int64(len(data)) > limit {
+		if decompressed {
+			return nil, fmt.Errorf("%w: decompressed limit is %d bytes", ErrBodyTooLarge, limit)
+		}
+		return nil, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, limit)
+	}
+	return data, nil
+}
+
+// pathTemplate returns the path of a request URL before path parameter replacement
+func pathTemplate(rawURL string) string {
+	path := rawURL
+	if _, rest, ok := strings.Cut(path, "://"); ok {
+		path = "/"
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			path = rest[i:]
+		}
+	}
+	path, _, _ = strings.Cut(path, "?")
+	return path
+}
+
 // shouldRetry determines if a request should be retried based on response and error
-func (c *Client) shouldRetry(resp *Response, err error) bool {
-	if c.retryCondition != nil {
-		return c.retryCondition(resp, err)
+func (c *Client) shouldRetry(condition RetryConditionFullFunc, resp *Response, err error, attempt int, elapsed time.Duration) bool {
+	if condition != nil {
+		return condition(resp, err, attempt, elapsed)
 	}
 
 	// Default retry logic
@@ -72,26 +314,230 @@ func (c *Client) shouldRetry(resp *Response, err error) bool {
 		return true // Retry on network errors
 	}
 
-	if resp != nil && (resp.StatusCode >= 500 || resp.StatusCode == 429) {
-		return true // Retry on server errors and rate limiting
+	if resp == nil {
+		return false
+	}
+
+	c.mu.RLock()
+	retryable, nonRetryable := c.retryableCodes[resp.StatusCode], c.nonRetryableCodes[resp.StatusCode]
+	c.mu.RUnlock()
+	if nonRetryable {
+		return false
+	}
+	if retryable {
+		return true
+	}
+
+	return resp.StatusCode >= 500 || resp.StatusCode == 429 // Retry on server errors and rate limiting
+}
+
+// fullRetryCondition adapts a RetryConditionFunc, keeping nil as nil
+func fullRetryCondition(condition RetryConditionFunc) RetryConditionFullFunc {
+	if condition == nil {
+		return nil
+	}
+	return func(resp *Response, err error, _ int, _ time.Duration) bool {
+		return condition(resp, err)
+	}
+}
+
+// withinRetryBudget reports whether waiting interval before another attempt
+// stays within the maximum retry elapsed time
+func (c *Client) withinRetryBudget(start time.Time, interval time.Duration) bool {
+	c.mu.RLock()
+	budget := c.maxRetryElapsed
+	c.mu.RUnlock()
+	return budget <= 0 || time.Since(start)+interval <= budget
+}
+
+// statusCodeSet builds a lookup set from a list of status codes
+func statusCodeSet(codes []int) map[int]bool {
+	set := make(map[int]bool, len(codes))
+	for _, code := range codes {
+		set[code] = true
+	}
+	return set
+}
+
+// waitRetry calls the retry hook and waits for the retry interval, returning
+// early with the context error if ctx is cancelled or expires
+func (c *Client) waitRetry(ctx context.Context, resp *Response, err error, attempt int, interval time.Duration) error {
+	if c.onRetry != nil {
+		c.onRetry(resp, err, attempt)
+	}
+	if c.debugEnabled() {
+		c.debugRetry(resp, err, attempt, interval)
 	}
 
-	return false
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// bindResult unmarshals the body into the request's success or error result
+func (c *Client) bindResult(req *Request, resp *Response) {
+	if resp.state == SuccessState && req.successResult != nil {
+		if err := c.unmarshalResponse(resp, req.successResult); err != nil {
+			resp.Err = fmt.Errorf("failed to unmarshal success result: %w", err)
+		}
+	} else if resp.state == ErrorState {
+		if req.errorResult != nil {
+			c.unmarshalResponse(resp, req.errorResult)
+		} else if c.commonErrorResult != nil {
+			c.unmarshalResponse(resp, c.commonErrorResult)
+		}
+	}
 }
 
 // unmarshalResponse unmarshals the response body into the given interface
+// using the decoder registered for the response Content-Type
 func (c *Client) unmarshalResponse(resp *Response, v interface{}) error {
 	if len(resp.body) == 0 {
 		return nil
 	}
 
-	contentType := resp.Header.Get("Content-Type")
-	if strings.Contains(contentType, "application/json") {
-		return c.jsonUnmarshal(resp.body, v)
-	} else if strings.Contains(contentType, "application/xml") || strings.Contains(contentType, "text/xml") {
-		return c.xmlUnmarshal(resp.body, v)
+	decode, err := c.decoderFor(resp.Request, resp.effectiveContentType())
+	if err != nil {
+		return err
+	}
+	return decode(resp.body, v)
+}
+
+// decoderFor returns the decoder for a Content-Type. Registered decoders take
+// precedence, then JSON, XML, MessagePack and protobuf; a missing Content-Type is treated as JSON.
+// Request-level JSON and XML unmarshal functions replace the client's.
+func (c *Client) decoderFor(req *Request, contentType string) (func([]byte, interface{}) error, error) {
+	mediaType := normalizeMediaType(contentType)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if decode, ok := c.decoders[mediaType]; ok {
+		return decode, nil
+	}
+
+	switch {
+	case mediaType == "", mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		if req != nil && req.jsonUnmarshal != nil {
+			return req.jsonUnmarshal, nil
+		}
+		if decode := c.jsonDecoder(req); decode != nil {
+			return decode, nil
+		}
+		return c.jsonUnmarshal, nil
+	case mediaType == "application/xml", mediaType == "text/xml", strings.HasSuffix(mediaType, "+xml"):
+		if req != nil && req.xmlUnmarshal != nil {
+			return req.xmlUnmarshal, nil
+		}
+		return c.xmlUnmarshal, nil
+	case mediaType == "application/msgpack", mediaType == "application/x-msgpack":
+		return c.msgpackUnmarshal, nil
+	case mediaType == "application/x-protobuf", mediaType == "application/protobuf":
+		return protoUnmarshal, nil
+	}
+	return nil, fmt.Errorf("no decoder registered for content type %q", mediaType)
+}
+
+// jsonDecoder returns a json.Decoder based unmarshal func honoring the
+// UseNumber and DisallowUnknownFields options, or nil when neither is set.
+// The caller must hold c.mu.
+func (c *Client) jsonDecoder(req *Request) func([]byte, interface{}) error {
+	useNumber, disallowUnknown := c.jsonUseNumber, c.strictJSON
+	if req != nil && req.strictJSON != nil {
+		disallowUnknown = *req.strictJSON
+	}
+	if !useNumber && !disallowUnknown {
+		return nil
+	}
+
+	return func(data []byte, v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		if useNumber {
+			decoder.UseNumber()
+		}
+		if disallowUnknown {
+			decoder.DisallowUnknownFields()
+		}
+		return decoder.Decode(v)
+	}
+}
+
+// normalizeMediaType strips parameters and lowercases a Content-Type value
+func normalizeMediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// quoteETag quotes a bare entity tag, leaving "*", quoted and weak tags unchanged
+func quoteETag(etag string) string {
+	if etag == "*" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// cancelOnClose cancels a context once the wrapped body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases its context
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// sizedReader is a streaming request body with a known size
+type sizedReader struct {
+	io.Reader
+	size int64
+}
+
+// progressReader reports how many bytes of a request body have been read
+type progressReader struct {
+	io.ReadCloser
+	written  int64
+	total    int64
+	callback func(written int64, total int64)
+}
+
+// Read reads from the body and reports progress
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.callback(p.written, p.total)
+	}
+	return n, err
+}
+
+// simpleCookies builds cookies from name/value pairs, sorted by name
+func simpleCookies(pairs map[string]string) []*http.Cookie {
+	names := make([]string, 0, len(pairs))
+	for name := range pairs {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	// Default to JSON
-	return c.jsonUnmarshal(resp.body, v)
+	cookies := make([]*http.Cookie, 0, len(names))
+	for _, name := range names {
+		cookies = append(cookies, &http.Cookie{Name: name, Value: pairs[name]})
+	}
+	return cookies
 }